@@ -0,0 +1,158 @@
+package igbinary
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// doctrineProxyMarker is the namespace segment Doctrine inserts into the class
+// names of its generated proxies, e.g. "Proxies\__CG__\App\Entity\Order".
+const doctrineProxyMarker = `\__CG__\`
+
+// RegisterClass maps the PHP class name to the Go type of prototype. Objects
+// of that class are then decoded into a new value of that type, anywhere in
+// the tree, instead of a map[string]any:
+//
+//	dec := igbinary.NewDecoder()
+//	dec.RegisterClass(`App\Entity\Order`, Order{})   // objects decode as Order
+//	dec.RegisterClass(`App\Entity\User`, &User{})    // objects decode as *User
+//
+// The object's properties are assigned with the same rules as
+// [Decoder.DecodeInto], so prototype may also implement [Unmarshaler].
+// Objects of unregistered classes keep the map form.
+//
+// Class names are matched case-insensitively, as in PHP. Doctrine proxy
+// classes ("Proxies\__CG__\App\Entity\Order") resolve to the class they
+// proxy, and [Decoder.RegisterNamespaceAlias] maps whole namespaces.
+//
+// RegisterClass is safe to call concurrently with decoding, but classes
+// registered while a decode is in progress may not apply to it.
+func (d *Decoder) RegisterClass(class string, prototype any) {
+	t := reflect.TypeOf(prototype)
+	if t == nil {
+		panic(fmt.Sprintf("igbinary: RegisterClass(%q) with nil prototype", class))
+	}
+	d.registry().register(class, t)
+}
+
+// RegisterNamespaceAlias makes classes in the PHP namespace alias resolve as
+// if they were declared in namespace target. This covers renamed namespaces
+// and class_alias() setups:
+//
+//	dec.RegisterClass(`App\Entity\Order`, Order{})
+//	dec.RegisterNamespaceAlias(`Legacy\Model`, `App\Entity`)
+//	// objects of class Legacy\Model\Order now decode as Order
+func (d *Decoder) RegisterNamespaceAlias(alias, target string) {
+	d.registry().alias(alias, target)
+}
+
+// registryMu guards the lazy creation of class registries.
+var registryMu sync.Mutex
+
+// registry returns the decoder's class registry, creating it for a zero
+// Decoder.
+func (d *Decoder) registry() *classRegistry {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if d.classes == nil {
+		d.classes = newClassRegistry()
+	}
+	return d.classes
+}
+
+// classRegistry maps PHP class names to Go types. It is safe for concurrent use.
+type classRegistry struct {
+	mu      sync.RWMutex
+	types   map[string]reflect.Type // lower-cased class name -> Go type
+	aliases []namespaceAlias        // sorted by descending prefix length
+}
+
+// namespaceAlias rewrites the lower-cased namespace prefix from to to.
+type namespaceAlias struct {
+	from, to string
+}
+
+func newClassRegistry() *classRegistry {
+	return &classRegistry{types: make(map[string]reflect.Type)}
+}
+
+// normalizeClass lower-cases a class name and strips any leading backslash.
+func normalizeClass(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, `\`))
+}
+
+func (c *classRegistry) register(class string, t reflect.Type) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.types[normalizeClass(class)] = t
+}
+
+func (c *classRegistry) alias(from, to string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aliases = append(c.aliases, namespaceAlias{
+		from: strings.TrimSuffix(normalizeClass(from), `\`) + `\`,
+		to:   strings.TrimSuffix(normalizeClass(to), `\`) + `\`,
+	})
+	sort.SliceStable(c.aliases, func(i, j int) bool {
+		return len(c.aliases[i].from) > len(c.aliases[j].from)
+	})
+}
+
+// lookup returns the Go type registered for a PHP class name, resolving
+// Doctrine proxies and namespace aliases.
+func (c *classRegistry) lookup(class string) (reflect.Type, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.types) == 0 {
+		return nil, false
+	}
+
+	name := normalizeClass(class)
+	if i := strings.Index(name, strings.ToLower(doctrineProxyMarker)); i >= 0 {
+		name = name[i+len(doctrineProxyMarker):]
+	}
+	if t, ok := c.types[name]; ok {
+		return t, true
+	}
+	for _, a := range c.aliases {
+		if rest, ok := strings.CutPrefix(name, a.from); ok {
+			if t, ok := c.types[a.to+rest]; ok {
+				return t, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// instantiate converts a decoded object into a new value of the Go type
+// registered for its class, if any, or into the data of an SPL class with
+// WithSPL.
+func (r *reader) instantiate(className string, obj any) (any, error) {
+	t, ok := r.classes.lookup(className)
+	if !ok {
+		if r.spl {
+			if val, ok, err := r.decodeSPL(className, obj); ok || err != nil {
+				return val, err
+			}
+		}
+		return obj, nil
+	}
+	v := reflect.New(t).Elem()
+	if err := assign(v, obj, nil); err != nil {
+		if (t == timeType || t == locationType) && errors.Is(err, ErrInvalidDateTime) {
+			// Keep dates Go cannot represent as they were decoded, rather
+			// than failing the whole payload.
+			return obj, nil
+		}
+		return nil, fmt.Errorf("object %q: %w", className, err)
+	}
+	return v.Interface(), nil
+}
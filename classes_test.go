@@ -0,0 +1,156 @@
+package igbinary_test
+
+import (
+	"errors"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+type classOrder struct {
+	ID    int64  `igbinary:"id"`
+	Class string `igbinary:"__class"`
+}
+
+type classCustomer struct {
+	Name string `igbinary:"name"`
+}
+
+// encodeObjects encodes a map of PHP objects keyed by name.
+func encodeObjects(t *testing.T, objects map[string]any) []byte {
+	t.Helper()
+	data, err := igbinary.Encode(objects)
+	assertNoError(t, err)
+	return data
+}
+
+func TestRegisterClassDecodesNestedObjects(t *testing.T) {
+	data := encodeObjects(t, map[string]any{
+		"order": map[string]any{igbinary.ClassKey: `App\Entity\Order`, "id": int64(5)},
+		"other": map[string]any{igbinary.ClassKey: `App\Entity\Other`, "x": int64(1)},
+	})
+
+	dec := igbinary.NewDecoder()
+	dec.RegisterClass(`App\Entity\Order`, classOrder{})
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+
+	m := val.(map[string]any)
+	order, ok := m["order"].(classOrder)
+	if !ok {
+		t.Fatalf("expected classOrder, got %T", m["order"])
+	}
+	if order.ID != 5 || order.Class != `App\Entity\Order` {
+		t.Errorf("unexpected order: %+v", order)
+	}
+	if _, ok := m["other"].(map[string]any); !ok {
+		t.Errorf("expected unregistered class to stay a map, got %T", m["other"])
+	}
+}
+
+func TestRegisterClassPointerPrototype(t *testing.T) {
+	data := encodeObjects(t, map[string]any{
+		"a": map[string]any{igbinary.ClassKey: `App\Customer`, "name": "Ann"},
+	})
+
+	dec := igbinary.NewDecoder()
+	dec.RegisterClass(`App\Customer`, &classCustomer{})
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+
+	c, ok := val.(map[string]any)["a"].(*classCustomer)
+	if !ok || c.Name != "Ann" {
+		t.Errorf("expected *classCustomer{Ann}, got %#v", val.(map[string]any)["a"])
+	}
+}
+
+func TestRegisterClassCaseInsensitiveAndLeadingBackslash(t *testing.T) {
+	data := encodeObjects(t, map[string]any{
+		"a": map[string]any{igbinary.ClassKey: `app\customer`, "name": "Ann"},
+	})
+
+	dec := igbinary.NewDecoder()
+	dec.RegisterClass(`\App\Customer`, classCustomer{})
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+	if _, ok := val.(map[string]any)["a"].(classCustomer); !ok {
+		t.Errorf("expected classCustomer, got %T", val.(map[string]any)["a"])
+	}
+}
+
+func TestRegisterClassDoctrineProxy(t *testing.T) {
+	data := encodeObjects(t, map[string]any{
+		"a": map[string]any{igbinary.ClassKey: `Proxies\__CG__\App\Customer`, "name": "Ann"},
+	})
+
+	dec := igbinary.NewDecoder()
+	dec.RegisterClass(`App\Customer`, classCustomer{})
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+	if _, ok := val.(map[string]any)["a"].(classCustomer); !ok {
+		t.Errorf("expected classCustomer, got %T", val.(map[string]any)["a"])
+	}
+}
+
+func TestRegisterNamespaceAlias(t *testing.T) {
+	data := encodeObjects(t, map[string]any{
+		"a": map[string]any{igbinary.ClassKey: `Legacy\Model\Customer`, "name": "Ann"},
+	})
+
+	dec := igbinary.NewDecoder()
+	dec.RegisterClass(`App\Entity\Customer`, classCustomer{})
+	dec.RegisterNamespaceAlias(`Legacy\Model`, `App\Entity`)
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+	if _, ok := val.(map[string]any)["a"].(classCustomer); !ok {
+		t.Errorf("expected classCustomer, got %T", val.(map[string]any)["a"])
+	}
+}
+
+func TestRegisterClassBackReferenceResolvesToGoValue(t *testing.T) {
+	shared := map[string]any{igbinary.ClassKey: `App\Customer`, "name": "Ann"}
+	data, err := igbinary.Encode([]any{shared, shared})
+	assertNoError(t, err)
+
+	dec := igbinary.NewDecoder()
+	dec.RegisterClass(`App\Customer`, &classCustomer{})
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+
+	m := val.(map[string]any)
+	first, ok1 := m["0"].(*classCustomer)
+	second, ok2 := m["1"].(*classCustomer)
+	if !ok1 || !ok2 || first != second {
+		t.Errorf("expected both entries to be the same *classCustomer, got %#v and %#v", m["0"], m["1"])
+	}
+}
+
+func TestRegisterClassTypeMismatch(t *testing.T) {
+	data := encodeObjects(t, map[string]any{
+		"a": map[string]any{igbinary.ClassKey: `App\Customer`, "name": int64(3)},
+	})
+
+	dec := igbinary.NewDecoder()
+	dec.RegisterClass(`App\Customer`, classCustomer{})
+	_, err := dec.Decode(data)
+	if !errors.Is(err, igbinary.ErrTypeMismatch) {
+		t.Errorf("expected ErrTypeMismatch, got: %v", err)
+	}
+}
+
+func TestRegisterClassZeroDecoder(t *testing.T) {
+	data := encodeObjects(t, map[string]any{
+		"order": map[string]any{igbinary.ClassKey: `Legacy\Order`, "id": int64(5)},
+	})
+
+	var dec igbinary.Decoder
+	dec.RegisterClass(`App\Entity\Order`, classOrder{})
+	dec.RegisterNamespaceAlias(`Legacy`, `App\Entity`)
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+	order, ok := val.(map[string]any)["order"].(classOrder)
+	if !ok {
+		t.Fatalf("expected classOrder, got %T", val.(map[string]any)["order"])
+	}
+	assertEqualInt64(t, order.ID, 5)
+}
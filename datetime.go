@@ -0,0 +1,368 @@
+package igbinary
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithDateTimes decodes PHP's date and time objects into Go types, anywhere
+// in the tree:
+//
+//   - DateTime, DateTimeImmutable and Carbon dates -> time.Time
+//   - DateTimeZone and CarbonTimeZone             -> *time.Location
+//   - DateInterval and CarbonInterval             -> [DateInterval]
+//
+// Dates keep the time zone PHP stored them with: a UTC offset, a zone
+// abbreviation such as "EST", or an IANA zone name loaded with
+// [time.LoadLocation]. Programs running where the system has no zone
+// database should import time/tzdata. Dates and zones that cannot be
+// converted, such as ones with an ambiguous abbreviation like "IST", stay
+// in the form [Decoder.Decode] gives other objects.
+//
+// Other subclasses of these classes can be registered the same way:
+//
+//	dec.RegisterClass(`App\Support\Date`, time.Time{})
+//
+// [Decoder.DecodeInto] fills time.Time, *time.Location and DateInterval
+// targets from such objects with or without this option, and [Encode] writes
+// time.Time values as DateTimeImmutable objects.
+func WithDateTimes() Option {
+	return func(d *Decoder) {
+		classes := d.registry()
+		for _, class := range []string{
+			"DateTime", "DateTimeImmutable",
+			`Carbon\Carbon`, `Carbon\CarbonImmutable`, `Illuminate\Support\Carbon`,
+		} {
+			classes.register(class, timeType)
+		}
+		for _, class := range []string{"DateTimeZone", `Carbon\CarbonTimeZone`} {
+			classes.register(class, locationType)
+		}
+		for _, class := range []string{"DateInterval", `Carbon\CarbonInterval`} {
+			classes.register(class, dateIntervalType)
+		}
+	}
+}
+
+var (
+	timeType         = reflect.TypeFor[time.Time]()
+	locationType     = reflect.TypeFor[*time.Location]()
+	dateIntervalType = reflect.TypeFor[DateInterval]()
+)
+
+// PHP's timezone_type values.
+const (
+	timezoneOffset = 1 // a UTC offset such as "+02:00"
+	timezoneAbbr   = 2 // a zone abbreviation such as "CEST"
+	timezoneID     = 3 // an IANA zone name such as "Europe/Berlin"
+)
+
+// phpDateFormat is the layout of the "date" property of a PHP DateTime,
+// after the year, which PHP writes with at least four digits and a sign
+// when negative.
+const phpDateFormat = "-01-02 15:04:05.000000"
+
+// zoneAbbreviations holds the UTC offsets, in seconds, of the zone
+// abbreviations PHP commonly stores with timezone_type 2.
+var zoneAbbreviations = map[string]int{
+	"UTC": 0, "GMT": 0, "Z": 0, "WET": 0, "WEST": 3600, "BST": 3600,
+	"CET": 3600, "CEST": 7200, "MET": 3600, "MEST": 7200,
+	"EET": 7200, "EEST": 10800, "MSK": 10800,
+	"EST": -18000, "EDT": -14400, "CST": -21600, "CDT": -18000,
+	"MST": -25200, "MDT": -21600, "PST": -28800, "PDT": -25200,
+	"AKST": -32400, "AKDT": -28800, "HST": -36000,
+	"AST": -14400, "ADT": -10800, "NST": -12600, "NDT": -9000,
+	"WAT": 3600, "CAT": 7200, "SAST": 7200, "EAT": 10800, "PKT": 18000,
+	"ICT": 25200, "WIB": 25200, "WITA": 28800, "WIT": 32400, "SGT": 28800,
+	"PHT": 28800, "JST": 32400, "KST": 32400, "HKT": 28800, "AWST": 28800,
+	"ACST": 34200, "ACDT": 37800, "AEST": 36000, "AEDT": 39600,
+	"NZST": 43200, "NZDT": 46800,
+}
+
+// locations caches the zones loaded by name, as [time.LoadLocation] reads
+// the zone database on every call.
+var locations sync.Map // string -> *time.Location
+
+// assignDateTime stores a decoded PHP DateTime or DateTimeZone object in
+// dst, if dst is a time.Time or a *time.Location. It reports whether dst has
+// one of those types.
+func assignDateTime(dst reflect.Value, src any, path *assignPath) (bool, error) {
+	var val any
+	var err error
+	switch dst.Type() {
+	case timeType:
+		if _, ok := entryString(src, "date"); !ok {
+			return true, typeError(src, dst.Type(), path)
+		}
+		val, err = phpDateTime(src)
+	case locationType:
+		if _, ok := entryString(src, "timezone"); !ok {
+			return true, typeError(src, dst.Type(), path)
+		}
+		val, err = phpLocation(src)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return true, fmt.Errorf("%w at %s: %v", ErrInvalidDateTime, path, err)
+	}
+	dst.Set(reflect.ValueOf(val))
+	return true, nil
+}
+
+// entryString returns the string stored under key in a decoded PHP object.
+func entryString(obj any, key string) (string, bool) {
+	val, _ := lookupEntry(obj, key)
+	switch s := val.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	}
+	return "", false
+}
+
+// entryInt returns the integer stored under key in a decoded PHP object.
+func entryInt(obj any, key string) int64 {
+	val, _ := lookupEntry(obj, key)
+	i, _ := val.(int64)
+	return i
+}
+
+// phpDateTime converts the properties of a PHP DateTime.
+func phpDateTime(obj any) (time.Time, error) {
+	date, _ := entryString(obj, "date")
+	loc, err := phpLocation(obj)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// time.Parse does not accept the negative and five-digit years PHP
+	// writes, nor dates without microseconds from PHP before 7.1.
+	var year, month, day, hour, minute, sec, usec int
+	n, _ := fmt.Sscanf(date, "%d-%d-%d %d:%d:%d.%d", &year, &month, &day, &hour, &minute, &sec, &usec)
+	if n < 6 {
+		return time.Time{}, fmt.Errorf("bad date %q", date)
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, sec, usec*1000, loc), nil
+}
+
+// phpLocation converts the timezone_type and timezone properties of a PHP
+// DateTime or DateTimeZone.
+func phpLocation(obj any) (*time.Location, error) {
+	name, _ := entryString(obj, "timezone")
+	switch typ := entryInt(obj, "timezone_type"); typ {
+	case timezoneOffset:
+		offset, ok := parseOffset(name)
+		if !ok {
+			return nil, fmt.Errorf("bad UTC offset %q", name)
+		}
+		return time.FixedZone(name, offset), nil
+	case timezoneAbbr:
+		if offset, ok := zoneAbbreviations[strings.ToUpper(name)]; ok {
+			return time.FixedZone(strings.ToUpper(name), offset), nil
+		}
+		// The zone database names a few zones after their abbreviation.
+		if loc, err := loadLocation(name); err == nil {
+			return loc, nil
+		}
+		return nil, fmt.Errorf("unknown time zone abbreviation %q", name)
+	case timezoneID:
+		return loadLocation(name)
+	default:
+		return nil, fmt.Errorf("unknown timezone_type %d", typ)
+	}
+}
+
+// parseOffset parses a UTC offset written as +HH:MM or +HHMM.
+func parseOffset(s string) (int, bool) {
+	if len(s) < 5 || s[0] != '+' && s[0] != '-' {
+		return 0, false
+	}
+	hhmm := strings.Replace(s[1:], ":", "", 1)
+	var h, m int
+	if len(hhmm) != 4 {
+		return 0, false
+	}
+	if _, err := fmt.Sscanf(hhmm, "%02d%02d", &h, &m); err != nil || m >= 60 {
+		return 0, false
+	}
+	offset := h*3600 + m*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, true
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if name == "UTC" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// phpTimezone returns the timezone_type and timezone PHP uses for loc at
+// time t: the zone name for IANA zones, the abbreviation for zones named
+// after one, and the UTC offset otherwise.
+func phpTimezone(loc *time.Location, t time.Time) (int64, string) {
+	name := loc.String()
+	_, offset := t.In(loc).Zone()
+	if name == "UTC" || strings.Contains(name, "/") {
+		return timezoneID, name
+	}
+	if o, ok := zoneAbbreviations[name]; ok && o == offset {
+		return timezoneAbbr, name
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return timezoneOffset, fmt.Sprintf("%c%02d:%02d", sign, offset/3600, offset/60%60)
+}
+
+// encodeTime writes t as a PHP DateTimeImmutable.
+func (w *writer) encodeTime(t time.Time) error {
+	yearFormat := "%04d"
+	if t.Year() < 0 {
+		yearFormat = "%05d"
+	}
+	date := fmt.Sprintf(yearFormat, t.Year()) + t.Format(phpDateFormat)
+	typ, zone := phpTimezone(t.Location(), t)
+
+	if err := w.writeObjectHeader("DateTimeImmutable", 3); err != nil {
+		return err
+	}
+	return w.writeProperties("date", date, "timezone_type", typ, "timezone", zone)
+}
+
+// encodeLocation writes loc as a PHP DateTimeZone.
+func (w *writer) encodeLocation(loc *time.Location) error {
+	typ, zone := phpTimezone(loc, time.Now())
+	if err := w.writeObjectHeader("DateTimeZone", 2); err != nil {
+		return err
+	}
+	return w.writeProperties("timezone_type", typ, "timezone", zone)
+}
+
+// writeProperties writes alternating property names and values.
+func (w *writer) writeProperties(kv ...any) error {
+	for i := 0; i < len(kv); i += 2 {
+		if err := w.writeKey(kv[i].(string)); err != nil {
+			return err
+		}
+		if err := w.encodeValue(kv[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DateInterval is a PHP DateInterval: a period of calendar units, which
+// unlike a time.Duration can span months and years of varying length.
+//
+// DateInterval implements [Marshaler] and [Unmarshaler], so it can be used
+// in struct fields; [WithDateTimes] decodes DateInterval objects into it.
+type DateInterval struct {
+	Years, Months, Days     int
+	Hours, Minutes, Seconds int
+	Microseconds            int
+	Invert                  bool // the interval is negative
+	TotalDays               int  // total number of days, if HasTotalDays
+	HasTotalDays            bool // set for intervals from DateTime::diff()
+}
+
+// DateIntervalOf returns the DateInterval of d in days, hours, minutes,
+// seconds and microseconds.
+func DateIntervalOf(d time.Duration) DateInterval {
+	var di DateInterval
+	if d < 0 {
+		di.Invert = true
+		d = -d
+	}
+	di.Days = int(d / (24 * time.Hour))
+	di.Hours = int(d / time.Hour % 24)
+	di.Minutes = int(d / time.Minute % 60)
+	di.Seconds = int(d / time.Second % 60)
+	di.Microseconds = int(d / time.Microsecond % 1e6)
+	return di
+}
+
+// Duration returns the interval as a time.Duration. Years and months have no
+// fixed length, so it reports false for intervals with either, unless the
+// total number of days is known.
+func (di DateInterval) Duration() (time.Duration, bool) {
+	days := di.Days
+	switch {
+	case di.HasTotalDays:
+		days = di.TotalDays
+	case di.Years != 0 || di.Months != 0:
+		return 0, false
+	}
+	d := time.Duration(days)*24*time.Hour +
+		time.Duration(di.Hours)*time.Hour +
+		time.Duration(di.Minutes)*time.Minute +
+		time.Duration(di.Seconds)*time.Second +
+		time.Duration(di.Microseconds)*time.Microsecond
+	if di.Invert {
+		d = -d
+	}
+	return d, true
+}
+
+// UnmarshalIgbinary implements [Unmarshaler] for PHP DateInterval objects.
+func (di *DateInterval) UnmarshalIgbinary(v Value) error {
+	if v.Kind() != Object {
+		return fmt.Errorf("%w: DateInterval from %s", ErrTypeMismatch, v.Kind())
+	}
+	num := func(key string) int { return int(v.Get(key).Int()) }
+	*di = DateInterval{
+		Years: num("y"), Months: num("m"), Days: num("d"),
+		Hours: num("h"), Minutes: num("i"), Seconds: num("s"),
+		Microseconds: int(math.Round(v.Get("f").Float() * 1e6)),
+		Invert:       num("invert") != 0,
+	}
+	if days := v.Get("days"); days.Kind() == Int {
+		di.TotalDays, di.HasTotalDays = int(days.Int()), true
+	}
+	return nil
+}
+
+// MarshalIgbinary implements [Marshaler], writing a PHP DateInterval.
+func (di DateInterval) MarshalIgbinary(e *Encoder) error {
+	var days any = false
+	if di.HasTotalDays {
+		days = di.TotalDays
+	}
+	invert := 0
+	if di.Invert {
+		invert = 1
+	}
+	e.WriteObjectHeader("DateInterval", 10)
+	for _, p := range []struct {
+		key string
+		val any
+	}{
+		{"y", di.Years}, {"m", di.Months}, {"d", di.Days},
+		{"h", di.Hours}, {"i", di.Minutes}, {"s", di.Seconds},
+		{"f", float64(di.Microseconds) / 1e6}, {"invert", invert},
+		{"days", days}, {"from_string", false},
+	} {
+		e.WriteKey(p.key)
+		if err := e.EncodeValue(p.val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
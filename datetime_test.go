@@ -0,0 +1,235 @@
+package igbinary_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// phpDateTime returns a payload holding a PHP date object as PHP stores it.
+func phpDateTime(t *testing.T, class, date string, typ int64, zone string) []byte {
+	t.Helper()
+	data, err := igbinary.Encode(map[string]any{
+		igbinary.ClassKey: class,
+		"date":            date,
+		"timezone_type":   typ,
+		"timezone":        zone,
+	})
+	assertNoError(t, err)
+	return data
+}
+
+func TestDecodeDateTimes(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assertNoError(t, err)
+
+	tests := []struct {
+		class, date string
+		typ         int64
+		zone        string
+		want        time.Time
+	}{
+		{"DateTime", "2021-03-04 05:06:07.123456", 3, "Europe/Berlin",
+			time.Date(2021, 3, 4, 5, 6, 7, 123456000, berlin)},
+		{"DateTimeImmutable", "2021-03-04 05:06:07.000000", 1, "+05:30",
+			time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", 19800))},
+		{`Carbon\Carbon`, "2021-03-04 05:06:07.000000", 2, "EST",
+			time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", -18000))},
+		{"DateTime", "-0001-11-30 00:00:00.000000", 3, "UTC",
+			time.Date(-1, 11, 30, 0, 0, 0, 0, time.UTC)},
+	}
+	dec := igbinary.NewDecoder(igbinary.WithDateTimes())
+	for _, tt := range tests {
+		val, err := dec.Decode(phpDateTime(t, tt.class, tt.date, tt.typ, tt.zone))
+		assertNoError(t, err)
+		got, ok := val.(time.Time)
+		if !ok {
+			t.Fatalf("%s: expected time.Time, got %T", tt.date, val)
+		}
+		_, offset := got.Zone()
+		_, wantOffset := tt.want.Zone()
+		if !got.Equal(tt.want) || offset != wantOffset {
+			t.Errorf("%s %s: expected %v, got %v", tt.date, tt.zone, tt.want, got)
+		}
+	}
+	if val, _ := dec.Decode(phpDateTime(t, "DateTime", "2021-03-04 05:06:07.000000", 3, "UTC")); val.(time.Time).Location() != time.UTC {
+		t.Error("expected UTC dates in time.UTC")
+	}
+}
+
+func TestDecodeDateTimesOptIn(t *testing.T) {
+	val, err := igbinary.Decode(phpDateTime(t, "DateTime", "2021-03-04 05:06:07.000000", 3, "UTC"))
+	assertNoError(t, err)
+	if _, ok := val.(map[string]any); !ok {
+		t.Errorf("expected a map without WithDateTimes, got %T", val)
+	}
+}
+
+func TestDecodeDateTimeInvalid(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithDateTimes())
+	for _, data := range [][]byte{
+		phpDateTime(t, "DateTime", "2021-03-04 05:06:07.000000", 3, "Nowhere/Atlantis"),
+		phpDateTime(t, "DateTime", "2021-03-04 05:06:07.000000", 2, "IST"),
+		phpDateTime(t, "DateTime", "2021-03-04 05:06:07.000000", 1, "5h"),
+		phpDateTime(t, "DateTime", "yesterday", 3, "UTC"),
+	} {
+		// ["when" => $date]: the date stays an object, and the rest of the
+		// payload still decodes.
+		data = makePayload(append([]byte{0x14, 0x01, 0x11, 0x04, 'w', 'h', 'e', 'n'}, data[4:]...)...)
+		val, err := dec.Decode(data)
+		assertNoError(t, err)
+		when := val.(map[string]any)["when"]
+		if m, ok := when.(map[string]any); !ok || m[igbinary.ClassKey] != "DateTime" {
+			t.Errorf("expected the DateTime object, got %#v", when)
+		}
+
+		var v struct {
+			When time.Time `igbinary:"when"`
+		}
+		err = dec.DecodeInto(data, &v)
+		if !errors.Is(err, igbinary.ErrInvalidDateTime) || !strings.Contains(err.Error(), "at $.when") {
+			t.Errorf("expected ErrInvalidDateTime at $.when, got %v", err)
+		}
+	}
+}
+
+func TestDecodeDateTimeZoneAbbreviations(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithDateTimes())
+	for zone, offset := range map[string]int{"SGT": 8 * 3600, "WIB": 7 * 3600, "EST5EDT": -5 * 3600} {
+		val, err := dec.Decode(phpDateTime(t, "DateTime", "2021-01-04 05:06:07.000000", 2, zone))
+		assertNoError(t, err)
+		got, ok := val.(time.Time)
+		if !ok {
+			t.Fatalf("%s: expected time.Time, got %T", zone, val)
+		}
+		if _, o := got.Zone(); o != offset {
+			t.Errorf("%s: expected offset %d, got %d", zone, offset, o)
+		}
+	}
+}
+
+func TestUnmarshalDateTypes(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{
+		"created": map[string]any{
+			igbinary.ClassKey: "DateTimeImmutable",
+			"date":            "2021-03-04 05:06:07.500000",
+			"timezone_type":   int64(3),
+			"timezone":        "UTC",
+		},
+		"zone": map[string]any{
+			igbinary.ClassKey: "DateTimeZone",
+			"timezone_type":   int64(1),
+			"timezone":        "-03:00",
+		},
+		"every": map[string]any{
+			igbinary.ClassKey: "DateInterval",
+			"y":               int64(0), "m": int64(0), "d": int64(1),
+			"h": int64(2), "i": int64(30), "s": int64(0), "f": 0.25,
+			"invert": int64(1), "days": false,
+		},
+	})
+	assertNoError(t, err)
+
+	var v struct {
+		Created time.Time             `igbinary:"created"`
+		Zone    *time.Location        `igbinary:"zone"`
+		Every   igbinary.DateInterval `igbinary:"every"`
+	}
+	assertNoError(t, igbinary.Unmarshal(data, &v))
+	if want := time.Date(2021, 3, 4, 5, 6, 7, 500000000, time.UTC); !v.Created.Equal(want) {
+		t.Errorf("expected %v, got %v", want, v.Created)
+	}
+	if _, offset := time.Now().In(v.Zone).Zone(); offset != -3*3600 {
+		t.Errorf("expected offset -03:00, got %d", offset)
+	}
+	d, ok := v.Every.Duration()
+	if want := -(26*time.Hour + 30*time.Minute + 250*time.Millisecond); !ok || d != want {
+		t.Errorf("expected %v, got %v (%v)", want, d, ok)
+	}
+}
+
+func TestEncodeTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assertNoError(t, err)
+
+	tests := []struct {
+		in       time.Time
+		date     string
+		typ      int64
+		zone     string
+		location *time.Location
+	}{
+		{time.Date(2021, 3, 4, 5, 6, 7, 123456789, berlin), "2021-03-04 05:06:07.123456", 3, "Europe/Berlin", berlin},
+		{time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), "2021-03-04 05:06:07.000000", 3, "UTC", time.UTC},
+		{time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", -9000)), "2021-03-04 05:06:07.000000", 1, "-02:30", nil},
+		{time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("EST", -18000)), "2021-03-04 05:06:07.000000", 2, "EST", nil},
+	}
+	for _, tt := range tests {
+		data, err := igbinary.Encode(tt.in)
+		assertNoError(t, err)
+		val, err := igbinary.Decode(data)
+		assertNoError(t, err)
+		m := val.(map[string]any)
+		assertEqualString(t, m[igbinary.ClassKey], "DateTimeImmutable")
+		assertEqualString(t, m["date"], tt.date)
+		assertEqualInt64(t, m["timezone_type"], tt.typ)
+		assertEqualString(t, m["timezone"], tt.zone)
+
+		var back time.Time
+		assertNoError(t, igbinary.Unmarshal(data, &back))
+		if !back.Equal(tt.in.Truncate(time.Microsecond)) {
+			t.Errorf("round trip: expected %v, got %v", tt.in, back)
+		}
+		if tt.location != nil && back.Location().String() != tt.location.String() {
+			t.Errorf("round trip: expected location %v, got %v", tt.location, back.Location())
+		}
+	}
+}
+
+func TestEncodeLocation(t *testing.T) {
+	data, err := igbinary.Encode(time.UTC)
+	assertNoError(t, err)
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	assertEqualString(t, m[igbinary.ClassKey], "DateTimeZone")
+	assertEqualInt64(t, m["timezone_type"], 3)
+	assertEqualString(t, m["timezone"], "UTC")
+}
+
+func TestDateInterval(t *testing.T) {
+	di := igbinary.DateIntervalOf(-(90*time.Minute + 1500*time.Millisecond))
+	want := igbinary.DateInterval{Hours: 1, Minutes: 30, Seconds: 1, Microseconds: 500000, Invert: true}
+	if di != want {
+		t.Fatalf("expected %+v, got %+v", want, di)
+	}
+
+	data, err := igbinary.Encode(di)
+	assertNoError(t, err)
+	val, err := igbinary.NewDecoder(igbinary.WithDateTimes()).Decode(data)
+	assertNoError(t, err)
+	if val != any(di) {
+		t.Errorf("round trip: expected %+v, got %#v", di, val)
+	}
+
+	if _, ok := (igbinary.DateInterval{Months: 1}).Duration(); ok {
+		t.Error("expected no duration for an interval of months")
+	}
+	diff := igbinary.DateInterval{Years: 1, Days: 2, TotalDays: 367, HasTotalDays: true}
+	if d, ok := diff.Duration(); !ok || d != 367*24*time.Hour {
+		t.Errorf("expected 367 days, got %v (%v)", d, ok)
+	}
+}
+
+func TestDecodeDateTimesZeroDecoder(t *testing.T) {
+	var dec igbinary.Decoder
+	igbinary.WithDateTimes()(&dec)
+	val, err := dec.Decode(phpDateTime(t, "DateTime", "2024-01-02 03:04:05.000000", 3, "UTC"))
+	assertNoError(t, err)
+	if _, ok := val.(time.Time); !ok {
+		t.Errorf("expected time.Time, got %T", val)
+	}
+}
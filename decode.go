@@ -3,6 +3,8 @@ package igbinary
 import (
 	"fmt"
 	"math"
+	"slices"
+	"strings"
 )
 
 // Decode decodes igbinary-serialized data into a Go value.
@@ -21,31 +23,49 @@ var defaultDecoder = NewDecoder()
 // Option configures a [Decoder].
 type Option func(*Decoder)
 
-// WithStrictMode enables strict decoding mode. In strict mode, the decoder
-// returns errors for conditions that would otherwise be silently handled
-// (e.g., unresolved references return an error instead of nil).
+// WithStrictMode used to make the decoder reject PHP references (&$var),
+// which it could not decode. They are decoded now, and a reference to a
+// value that does not exist is always reported as [ErrValueRefOutOfRange].
+//
+// Deprecated: The option has no effect.
 func WithStrictMode(strict bool) Option {
-	return func(d *Decoder) {
-		d.strict = strict
-	}
+	return func(*Decoder) {}
 }
 
 // Decoder decodes igbinary-serialized binary data into Go values.
 //
 // A Decoder is safe for concurrent use: each call to [Decoder.Decode] creates
-// its own internal state. The Decoder itself only holds configuration.
+// its own internal state. The Decoder itself only holds configuration and the
+// class registry populated by [Decoder.RegisterClass].
 type Decoder struct {
-	strict    bool
-	normalize bool
+	normalize     bool
+	normalizeOpts []NormalizeOption
+	ordered       bool
+	keyKinds      bool
+	refs          bool
+	zeroCopy      bool
+	byteStrings   bool
+	intern        *InternPool
+	limits        limits
+	noHeader      bool
+	noTrailing    bool
+	large         largeStrings
+	classes       *classRegistry
+
+	decodeSerialized bool
+	spl              bool
 }
 
 // NewDecoder creates a new Decoder with the given options.
 //
 //	dec := igbinary.NewDecoder(
-//	    igbinary.WithStrictMode(true),
+//	    igbinary.WithOrderedMaps(),
 //	)
 func NewDecoder(opts ...Option) *Decoder {
-	d := &Decoder{}
+	d := &Decoder{
+		limits:  limits{maxDepth: DefaultMaxDepth},
+		classes: newClassRegistry(),
+	}
 	for _, opt := range opts {
 		opt(d)
 	}
@@ -56,41 +76,114 @@ func NewDecoder(opts ...Option) *Decoder {
 //
 // The data must include the 4-byte igbinary header (00 00 00 02 for version 2).
 func (d *Decoder) Decode(data []byte) (any, error) {
-	if len(data) < 5 {
-		return nil, newError(ErrDataTooShort,
-			0, fmt.Sprintf("%d bytes (need at least 5)", len(data)))
-	}
-
-	// Validate header: 00 00 00 02
-	if data[0] != 0x00 || data[1] != 0x00 || data[2] != 0x00 || data[3] != FormatVersion {
-		return nil, newError(ErrInvalidHeader,
-			0, fmt.Sprintf("got %02x %02x %02x %02x, want 00 00 00 %02x",
-				data[0], data[1], data[2], data[3], FormatVersion))
-	}
-
-	r := &reader{
-		data:   data,
-		pos:    4, // skip header
-		strict: d.strict,
+	r, err := d.newReader(data)
+	if err != nil {
+		return nil, err
 	}
 
 	val, err := r.decodeValue()
 	if err != nil {
 		return nil, err
 	}
+	if d.noTrailing {
+		if err := r.checkEnd(); err != nil {
+			return nil, err
+		}
+	}
 	if d.normalize {
-		val = NormalizeArrays(val)
+		val = NormalizeArrays(val, d.normalizeOpts...)
 	}
 	return val, nil
 }
 
+// newReader validates the igbinary header of data and returns a reader
+// positioned at the first value, configured from d.
+func (d *Decoder) newReader(data []byte) (*reader, error) {
+	return d.newReaderAt(data, 0)
+}
+
+// newReaderAt is like newReader for a payload starting at offset in data.
+// Without a header, the reader starts at offset itself.
+func (d *Decoder) newReaderAt(data []byte, offset int) (*reader, error) {
+	if offset < 0 || offset > len(data) {
+		return nil, newError(ErrDataTooShort,
+			0, fmt.Sprintf("offset %d outside %d bytes", offset, len(data)))
+	}
+	pos := offset
+	if d.noHeader {
+		if len(data)-offset < 1 {
+			return nil, newError(ErrDataTooShort,
+				offset, fmt.Sprintf("%d bytes (need at least 1)", len(data)-offset))
+		}
+	} else {
+		if len(data)-offset < 5 {
+			return nil, newError(ErrDataTooShort,
+				offset, fmt.Sprintf("%d bytes (need at least 5)", len(data)-offset))
+		}
+
+		// Validate header: 00 00 00 02
+		h := data[offset : offset+4]
+		if h[0] != 0x00 || h[1] != 0x00 || h[2] != 0x00 || h[3] != FormatVersion {
+			return nil, newError(ErrInvalidHeader,
+				offset, fmt.Sprintf("got %02x %02x %02x %02x, want 00 00 00 %02x",
+					h[0], h[1], h[2], h[3], FormatVersion))
+		}
+		pos += 4 // skip header
+	}
+
+	return &reader{
+		data:        data,
+		pos:         pos,
+		ordered:     d.ordered,
+		keyKinds:    d.keyKinds,
+		refs:        d.refs,
+		zeroCopy:    d.zeroCopy,
+		byteStrings: d.byteStrings,
+		intern:      d.intern,
+		limits:      d.limits,
+		large:       d.large,
+		classes:     d.classes,
+
+		decodeSerialized: d.decodeSerialized,
+		spl:              d.spl,
+	}, nil
+}
+
 // reader holds the mutable state for a single decode operation.
 type reader struct {
-	data    []byte
-	pos     int
-	strings []string // string deduplication table
-	values  []any    // compound value reference table (arrays and objects)
-	strict  bool
+	data     []byte
+	pos      int
+	strings  []string // string deduplication table
+	nstrings int      // strings registered so far; see readAndRegisterString
+	values   []any    // compound value reference table (arrays and objects)
+	ordered  bool
+	keyKinds bool
+	refs     bool       // return back-references and PHP references as *Reference
+	tree     bool       // build a Value tree, with objects as *object nodes
+	nextRef  *Reference // the PHP reference the next array or object is decoded for
+	classes  *classRegistry
+
+	decodeSerialized bool // decode the data of serialized objects
+	spl              bool // decode SPL classes into the data they hold
+
+	zeroCopy    bool     // strings share memory with data
+	byteStrings bool     // string values are []byte subslices of data
+	stringBytes [][]byte // the string table as subslices, with byteStrings
+	intern      *InternPool
+
+	limits    limits
+	depth     int   // arrays and objects currently open
+	elements  int   // array entries and object properties declared so far
+	allocated int64 // approximate bytes allocated so far, see alloc
+
+	large       largeStrings
+	largeByID   map[int]*LargeString // placeholders by string table ID
+	sinkWritten int64                // bytes written to large.sink
+	path        []Key                // keys leading to the current value, with large strings
+
+	partial bool           // recover from errors, see DecodePartial
+	broken  bool           // an unrecoverable error ended the partial decode
+	errs    []*DecodeError // errors recovered from so far
 }
 
 // --- Low-level read primitives ---
@@ -146,11 +239,20 @@ func (r *reader) readUint64() (uint64, error) {
 // --- Value decoding ---
 
 func (r *reader) decodeValue() (any, error) {
+	start := r.pos
 	code, err := r.readByte()
 	if err != nil {
 		return nil, err
 	}
+	v, err := r.decodeCode(code)
+	if err != nil {
+		return nil, withCode(err, code, start)
+	}
+	return v, nil
+}
 
+// decodeCode decodes the value introduced by the type code just read.
+func (r *reader) decodeCode(code byte) (any, error) {
 	switch code {
 	case TypeNil:
 		return nil, nil
@@ -202,33 +304,36 @@ func (r *reader) decodeValue() (any, error) {
 		// Empty strings are NOT registered in the dedup table.
 		// PHP igbinary uses type_string_empty as a special marker
 		// that does not occupy a slot in the string table.
+		if r.byteStrings {
+			return r.data[r.pos:r.pos:r.pos], nil
+		}
 		return "", nil
-	case TypeString8:
-		return r.decodeNewString8()
-	case TypeString16:
-		return r.decodeNewString16()
-	case TypeString32:
-		return r.decodeNewString32()
-
-	// String back-references
-	case TypeStringID8:
-		v, err := r.readUint8()
+	case TypeString8, TypeString16, TypeString32:
+		if r.large.threshold > 0 {
+			if v, ok, err := r.decodeLargeString(code); ok || err != nil {
+				return v, err
+			}
+		}
+		s, err := r.decodeNewString(code)
 		if err != nil {
 			return nil, err
 		}
-		return r.lookupString(int(v))
-	case TypeStringID16:
-		v, err := r.readUint16()
+		return r.stringValue(r.nstrings-1, s), nil
+
+	// String back-references
+	case TypeStringID8, TypeStringID16, TypeStringID32:
+		id, err := r.readSized(code, TypeStringID8)
 		if err != nil {
 			return nil, err
 		}
-		return r.lookupString(int(v))
-	case TypeStringID32:
-		v, err := r.readUint32()
+		if ls, ok := r.largeByID[id]; ok {
+			return ls, nil
+		}
+		s, err := r.lookupString(id)
 		if err != nil {
-			return nil, err
+			return nil, resumable(err)
 		}
-		return r.lookupString(int(v))
+		return r.stringValue(id, s), nil
 
 	// Arrays
 	case TypeArray8:
@@ -270,11 +375,7 @@ func (r *reader) decodeValue() (any, error) {
 
 	// Simple reference (&$var)
 	case TypeSimpleRef:
-		if r.strict {
-			return nil, newError(ErrUnknownType, r.pos-1,
-				"simple references are not fully supported in strict mode")
-		}
-		return nil, nil
+		return r.decodeSimpleRef()
 
 	default:
 		return nil, newError(ErrUnknownType, r.pos-1,
@@ -284,44 +385,49 @@ func (r *reader) decodeValue() (any, error) {
 
 // --- String helpers ---
 
-func (r *reader) decodeNewString8() (string, error) {
-	length, err := r.readUint8()
+// decodeNewString reads and registers the string that follows a TypeString8,
+// TypeString16 or TypeString32 code.
+func (r *reader) decodeNewString(code byte) (string, error) {
+	length, err := r.readSized(code, TypeString8)
 	if err != nil {
 		return "", err
 	}
-	return r.readAndRegisterString(int(length))
+	return r.readAndRegisterString(length)
 }
 
-func (r *reader) decodeNewString16() (string, error) {
-	length, err := r.readUint16()
-	if err != nil {
+func (r *reader) readAndRegisterString(length int) (string, error) {
+	if err := r.checkString(length); err != nil {
 		return "", err
 	}
-	return r.readAndRegisterString(int(length))
-}
-
-func (r *reader) decodeNewString32() (string, error) {
-	length, err := r.readUint32()
+	b, err := r.readBytes(length)
 	if err != nil {
 		return "", err
 	}
-	return r.readAndRegisterString(int(length))
-}
-
-func (r *reader) readAndRegisterString(length int) (string, error) {
-	b, err := r.readBytes(length)
-	if err != nil {
+	// A table filled by an earlier pass over the same bytes already holds
+	// the string.
+	if r.nstrings < len(r.strings) {
+		r.nstrings++
+		return r.strings[r.nstrings-1], nil
+	}
+	if err := r.alloc(int64(length)); err != nil {
 		return "", err
 	}
-	s := string(b)
+	s := r.makeString(b)
 	r.strings = append(r.strings, s)
+	if r.byteStrings {
+		r.stringBytes = append(r.stringBytes, b[:len(b):len(b)])
+	}
+	r.nstrings++
 	return s, nil
 }
 
 func (r *reader) lookupString(id int) (string, error) {
-	if id < 0 || id >= len(r.strings) {
+	if id < 0 || id >= r.nstrings {
 		return "", newError(ErrStringIDOutOfRange, r.pos,
-			fmt.Sprintf("ID %d, table size %d", id, len(r.strings)))
+			fmt.Sprintf("ID %d, table size %d", id, r.nstrings))
+	}
+	if _, ok := r.largeByID[id]; ok {
+		return strings.Clone(r.strings[id]), nil
 	}
 	return r.strings[id], nil
 }
@@ -331,102 +437,185 @@ func (r *reader) lookupValue(id int) (any, error) {
 		return nil, newError(ErrValueRefOutOfRange, r.pos,
 			fmt.Sprintf("ID %d, table size %d", id, len(r.values)))
 	}
+	if s, ok := r.values[id].(*skippedValue); ok {
+		return r.resolveSkipped(s)
+	}
 	return r.values[id], nil
 }
 
 // --- Array decoding ---
 
+// newArray creates the container for a PHP array or object with room for
+// size entries, or as many as the remaining input can hold: a
+// map[string]any, an *OrderedMap in ordered mode, or a map[Key]any when key
+// kinds are kept.
+func (r *reader) newArray(size int) any {
+	size = r.capacity(size)
+	switch {
+	case r.ordered:
+		return NewOrderedMap(size)
+	case r.keyKinds:
+		return make(map[Key]any, size)
+	}
+	return make(map[string]any, size)
+}
+
+// setEntry stores one decoded entry in a container created by newArray.
+func setEntry(container any, key Key, val any) {
+	switch c := container.(type) {
+	case map[string]any:
+		c[key.String()] = val
+	case *OrderedMap:
+		c.SetKey(key, val)
+	case map[Key]any:
+		c[key] = val
+	case *object:
+		c.props.SetKey(key, val)
+	}
+}
+
+// newObject creates the container for an object of class with room for size
+// properties: an *object in a Value tree, or else a newArray container
+// holding the class under [ClassKey].
+func (r *reader) newObject(class string, size int) any {
+	if r.tree {
+		return &object{class: class, props: NewOrderedMap(r.capacity(size))}
+	}
+	m := r.newArray(size + 1)
+	setEntry(m, StringKey(ClassKey), class)
+	return m
+}
+
+// newSerializedObject creates a serialized object of class stored as raw,
+// with the decoded data val when decoded is set.
+func (r *reader) newSerializedObject(class string, raw []byte, val any, decoded bool) any {
+	if r.tree {
+		return &object{class: class, raw: r.makeString(raw), value: val, decoded: decoded}
+	}
+	m := r.newArray(3)
+	setEntry(m, StringKey(ClassKey), class)
+	setEntry(m, StringKey(SerializedDataKey), r.makeString(raw))
+	if decoded {
+		setEntry(m, StringKey(SerializedValueKey), val)
+	}
+	return m
+}
+
 func (r *reader) decodeArray(size int) (any, error) {
-	m := make(map[string]any, size)
+	if err := r.enter(size); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	m := r.newArray(size)
 	// Register in the values table before populating so that back-references
 	// from nested values can resolve to this map (handles circular refs).
-	r.values = append(r.values, m)
+	r.register(m)
 
-	for i := 0; i < size; i++ {
+	for i := 0; i < size && !r.broken; i++ {
 		key, err := r.decodeArrayKey()
 		if err != nil {
-			return nil, fmt.Errorf("array key %d: %w", i, err)
+			err = withDetail(err, fmt.Sprintf("key of entry %d", i))
+			if !r.partial {
+				return nil, err
+			}
+			r.salvage(err)
+			r.broken = true
+			break
 		}
 
-		val, err := r.decodeValue()
+		val, err := r.decodeEntry(PathElem{Key: key, Index: i})
 		if err != nil {
-			return nil, fmt.Errorf("array value for key %q: %w", key, err)
+			return nil, err
 		}
-
-		m[key] = val
+		setEntry(m, key, val)
 	}
 
 	return m, nil
 }
 
-func (r *reader) decodeArrayKey() (string, error) {
+func (r *reader) decodeArrayKey() (Key, error) {
 	code, err := r.readByte()
 	if err != nil {
-		return "", err
+		return Key{}, err
 	}
 
 	switch code {
 	// String keys
 	case TypeStringEmpty:
 		// Empty strings are NOT registered in the dedup table.
-		return "", nil
-	case TypeString8:
-		return r.decodeNewString8()
-	case TypeString16:
-		return r.decodeNewString16()
-	case TypeString32:
-		return r.decodeNewString32()
-	case TypeStringID8:
-		v, err := r.readUint8()
+		return StringKey(""), nil
+	case TypeString8, TypeString16, TypeString32:
+		return stringKey(r.decodeNewString(code))
+	case TypeStringID8, TypeStringID16, TypeStringID32:
+		id, err := r.readSized(code, TypeStringID8)
 		if err != nil {
-			return "", err
+			return Key{}, err
 		}
-		return r.lookupString(int(v))
-	case TypeStringID16:
-		v, err := r.readUint16()
-		if err != nil {
-			return "", err
-		}
-		return r.lookupString(int(v))
-	case TypeStringID32:
-		v, err := r.readUint32()
-		if err != nil {
-			return "", err
-		}
-		return r.lookupString(int(v))
+		return stringKey(r.lookupString(id))
 
-	// Integer keys (converted to string representation)
+	// Integer keys
 	case TypePosInt8:
 		v, err := r.readUint8()
-		return fmt.Sprintf("%d", v), err
+		return IntKey(int64(v)), err
 	case TypeNegInt8:
 		v, err := r.readUint8()
-		return fmt.Sprintf("-%d", v), err
+		return IntKey(-int64(v)), err
 	case TypePosInt16:
 		v, err := r.readUint16()
-		return fmt.Sprintf("%d", v), err
+		return IntKey(int64(v)), err
 	case TypeNegInt16:
 		v, err := r.readUint16()
-		return fmt.Sprintf("-%d", v), err
+		return IntKey(-int64(v)), err
 	case TypePosInt32:
 		v, err := r.readUint32()
-		return fmt.Sprintf("%d", v), err
+		return IntKey(int64(v)), err
 	case TypeNegInt32:
 		v, err := r.readUint32()
-		return fmt.Sprintf("-%d", v), err
+		return IntKey(-int64(v)), err
 	case TypePosInt64:
 		v, err := r.readUint64()
-		return fmt.Sprintf("%d", v), err
+		return IntKey(int64(v)), err
 	case TypeNegInt64:
 		v, err := r.readUint64()
-		return fmt.Sprintf("-%d", v), err
+		return IntKey(-int64(v)), err
 
 	default:
-		return "", newError(ErrUnsupportedArrayKey, r.pos-1,
+		return Key{}, newError(ErrUnsupportedArrayKey, r.pos-1,
 			fmt.Sprintf("0x%02x", code))
 	}
 }
 
+// decodeEntry decodes the value of the array entry or object property elem.
+func (r *reader) decodeEntry(elem PathElem) (any, error) {
+	if r.large.threshold > 0 {
+		r.path = append(r.path, elem.Key)
+		defer func() { r.path = r.path[:len(r.path)-1] }()
+	}
+	if !r.partial {
+		val, err := r.decodeValue()
+		if err != nil {
+			return nil, withPathElem(err, elem)
+		}
+		return val, nil
+	}
+
+	before := len(r.errs)
+	val, err := r.decodeValue()
+	if err != nil {
+		val = r.salvage(err)
+	}
+	for _, de := range r.errs[before:] {
+		de.Path = slices.Insert(de.Path, 0, elem)
+	}
+	return val, nil
+}
+
+// stringKey wraps the result of a string read as a Key.
+func stringKey(s string, err error) (Key, error) {
+	return StringKey(s), err
+}
+
 // --- Object decoding ---
 
 func (r *reader) decodeObject(code byte) (any, error) {
@@ -475,7 +664,7 @@ func (r *reader) decodeObjectByID(code byte) (any, error) {
 
 	className, err := r.lookupString(classID)
 	if err != nil {
-		return nil, fmt.Errorf("object class ID: %w", err)
+		return nil, withDetail(err, "object class ID")
 	}
 
 	return r.decodeObjectProperties(className)
@@ -507,25 +696,66 @@ func (r *reader) decodeObjectProperties(className string) (any, error) {
 		return nil, err
 	}
 
-	m := make(map[string]any, propCount+1)
-	m[ClassKey] = className
+	if err := r.enter(propCount); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	m := r.newObject(className, propCount)
 	// Register in the values table before populating so that back-references
 	// from nested values can resolve to this object.
-	r.values = append(r.values, m)
+	id := r.register(m)
 
-	for i := 0; i < propCount; i++ {
+	for i := 0; i < propCount && !r.broken; i++ {
 		key, keyErr := r.decodeArrayKey()
 		if keyErr != nil {
-			return nil, fmt.Errorf("object %q property key %d: %w", className, i, keyErr)
+			keyErr = withDetail(keyErr, fmt.Sprintf("key of property %d", i))
+			if !r.partial {
+				return nil, keyErr
+			}
+			r.salvage(keyErr)
+			r.broken = true
+			break
 		}
-		val, valErr := r.decodeValue()
+		val, valErr := r.decodeEntry(PathElem{Key: key, Index: i, Class: className})
 		if valErr != nil {
-			return nil, fmt.Errorf("object %q property %q: %w", className, key, valErr)
+			return nil, valErr
 		}
-		m[key] = val
+		setEntry(m, key, val)
 	}
 
-	return m, nil
+	return r.registerInstance(id, className, m)
+}
+
+// register adds the array or object m to the values table and returns its
+// ID. An array or object decoded for a PHP reference is registered as that
+// reference, see decodeSimpleRef.
+func (r *reader) register(m any) int {
+	id := len(r.values)
+	if ref := r.nextRef; ref != nil {
+		r.nextRef = nil
+		ref.Target = m
+		r.values = append(r.values, ref)
+		return id
+	}
+	r.values = append(r.values, m)
+	return id
+}
+
+// registerInstance converts a decoded object into its registered Go type and
+// replaces the values table entry, so later back-references resolve to the
+// converted value. References from inside the object itself keep the map form.
+func (r *reader) registerInstance(id int, className string, m any) (any, error) {
+	v, err := r.instantiate(className, m)
+	if err != nil {
+		return nil, err
+	}
+	if ref, ok := r.values[id].(*Reference); ok {
+		ref.Target = v
+	} else {
+		r.values[id] = v
+	}
+	return v, nil
 }
 
 func (r *reader) decodeObjectSerialized(code byte) (any, error) {
@@ -575,17 +805,27 @@ func (r *reader) decodeObjectSerialized(code byte) (any, error) {
 		return nil, err
 	}
 
+	if err := r.checkString(dataLen); err != nil {
+		return nil, err
+	}
+	if err := r.alloc(int64(dataLen)); err != nil {
+		return nil, err
+	}
 	raw, err := r.readBytes(dataLen)
 	if err != nil {
 		return nil, err
 	}
 
-	m := map[string]any{
-		ClassKey:          className,
-		SerializedDataKey: string(raw),
+	var val any
+	var decoded bool
+	if r.decodeSerialized {
+		if val, decoded, err = r.decodeSerializedData(raw); err != nil {
+			return nil, err
+		}
 	}
-	r.values = append(r.values, m)
-	return m, nil
+	m := r.newSerializedObject(className, raw, val, decoded)
+	id := r.register(m)
+	return r.registerInstance(id, className, m)
 }
 
 // --- Reference decoding ---
@@ -609,5 +849,16 @@ func (r *reader) decodeRef(code byte) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	return r.lookupValue(id)
+	target, err := r.lookupValue(id)
+	if err != nil {
+		return nil, resumable(err)
+	}
+	if !r.refs {
+		return target, nil
+	}
+	if ref, ok := target.(*Reference); ok && ref.Simple {
+		return ref, nil
+	}
+	isObject := code == TypeObjectRef8 || code == TypeObjectRef16 || code == TypeObjectRef32
+	return &Reference{Object: isObject, Target: target}, nil
 }
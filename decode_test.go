@@ -407,31 +407,34 @@ func TestDecodeObjectRefOutOfRange(t *testing.T) {
 }
 
 func TestDecodeSimpleRef(t *testing.T) {
-	data := makePayload(0x25) // TypeSimpleRef
+	data := makePayload(0x25, 0x06, 0x05) // TypeSimpleRef to int(5)
 	val, err := igbinary.Decode(data)
 	assertNoError(t, err)
-	if val != nil {
-		t.Errorf("expected nil for simple ref, got %v", val)
+	assertEqualInt64(t, val, 5)
+}
+
+func TestDecodeSimpleRefTruncated(t *testing.T) {
+	_, err := igbinary.Decode(makePayload(0x25))
+	if !errors.Is(err, igbinary.ErrUnexpectedEnd) {
+		t.Errorf("expected ErrUnexpectedEnd, got: %v", err)
 	}
 }
 
 // --- Strict Mode ---
 
-func TestStrictModeRejectsSimpleRef(t *testing.T) {
+func TestStrictModeAcceptsSimpleRef(t *testing.T) {
 	dec := igbinary.NewDecoder(igbinary.WithStrictMode(true))
-	data := makePayload(0x25) // TypeSimpleRef
-	_, err := dec.Decode(data)
-	if err == nil {
-		t.Fatal("expected error in strict mode for simple ref")
-	}
+	data := makePayload(0x25, 0x06, 0x05) // TypeSimpleRef to int(5)
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+	assertEqualInt64(t, val, 5)
 }
 
-func TestStrictModeRejectsArrayRef(t *testing.T) {
-	dec := igbinary.NewDecoder(igbinary.WithStrictMode(true))
+func TestDecodeUnresolvedArrayRef(t *testing.T) {
 	data := makePayload(0x01, 0x00) // TypeArrayRef8
-	_, err := dec.Decode(data)
-	if err == nil {
-		t.Fatal("expected error in strict mode for array ref")
+	_, err := igbinary.Decode(data)
+	if !errors.Is(err, igbinary.ErrValueRefOutOfRange) {
+		t.Fatalf("expected ErrValueRefOutOfRange for unresolved array ref, got: %v", err)
 	}
 }
 
@@ -1112,50 +1115,45 @@ func TestDecodeObjectSerializedInvalidDataLenCode(t *testing.T) {
 	}
 }
 
-// --- Strict mode for all ref types ---
+// --- Unresolved references of all widths ---
 
-func TestStrictModeRejectsObjectRef8(t *testing.T) {
-	dec := igbinary.NewDecoder(igbinary.WithStrictMode(true))
+func TestDecodeUnresolvedObjectRef8(t *testing.T) {
 	data := makePayload(0x22, 0x00) // TypeObjectRef8
-	_, err := dec.Decode(data)
-	if err == nil {
-		t.Fatal("expected error in strict mode for object ref8")
+	_, err := igbinary.Decode(data)
+	if !errors.Is(err, igbinary.ErrValueRefOutOfRange) {
+		t.Fatalf("expected ErrValueRefOutOfRange for unresolved object ref8, got: %v", err)
 	}
 }
 
-func TestStrictModeRejectsArrayRef16(t *testing.T) {
-	dec := igbinary.NewDecoder(igbinary.WithStrictMode(true))
+func TestDecodeUnresolvedArrayRef16(t *testing.T) {
 	data := makePayload(0x02, 0x00, 0x00) // TypeArrayRef16
-	_, err := dec.Decode(data)
-	if err == nil {
-		t.Fatal("expected error in strict mode for array ref16")
+	_, err := igbinary.Decode(data)
+	if !errors.Is(err, igbinary.ErrValueRefOutOfRange) {
+		t.Fatalf("expected ErrValueRefOutOfRange for unresolved array ref16, got: %v", err)
 	}
 }
 
-func TestStrictModeRejectsArrayRef32(t *testing.T) {
-	dec := igbinary.NewDecoder(igbinary.WithStrictMode(true))
+func TestDecodeUnresolvedArrayRef32(t *testing.T) {
 	data := makePayload(0x03, 0x00, 0x00, 0x00, 0x00) // TypeArrayRef32
-	_, err := dec.Decode(data)
-	if err == nil {
-		t.Fatal("expected error in strict mode for array ref32")
+	_, err := igbinary.Decode(data)
+	if !errors.Is(err, igbinary.ErrValueRefOutOfRange) {
+		t.Fatalf("expected ErrValueRefOutOfRange for unresolved array ref32, got: %v", err)
 	}
 }
 
-func TestStrictModeRejectsObjectRef16(t *testing.T) {
-	dec := igbinary.NewDecoder(igbinary.WithStrictMode(true))
+func TestDecodeUnresolvedObjectRef16(t *testing.T) {
 	data := makePayload(0x23, 0x00, 0x00) // TypeObjectRef16
-	_, err := dec.Decode(data)
-	if err == nil {
-		t.Fatal("expected error in strict mode for object ref16")
+	_, err := igbinary.Decode(data)
+	if !errors.Is(err, igbinary.ErrValueRefOutOfRange) {
+		t.Fatalf("expected ErrValueRefOutOfRange for unresolved object ref16, got: %v", err)
 	}
 }
 
-func TestStrictModeRejectsObjectRef32(t *testing.T) {
-	dec := igbinary.NewDecoder(igbinary.WithStrictMode(true))
+func TestDecodeUnresolvedObjectRef32(t *testing.T) {
 	data := makePayload(0x24, 0x00, 0x00, 0x00, 0x00) // TypeObjectRef32
-	_, err := dec.Decode(data)
-	if err == nil {
-		t.Fatal("expected error in strict mode for object ref32")
+	_, err := igbinary.Decode(data)
+	if !errors.Is(err, igbinary.ErrValueRefOutOfRange) {
+		t.Fatalf("expected ErrValueRefOutOfRange for unresolved object ref32, got: %v", err)
 	}
 }
 
@@ -1251,6 +1249,67 @@ func TestDecodeErrorUnwrap(t *testing.T) {
 	}
 }
 
+// --- DecodeError paths ---
+
+// truncatedOrder is ["orders" => [0 => Order{"customer" => ["address" => "Ma]]]],
+// cut off inside the address string.
+var truncatedOrder = makePayload(
+	0x14, 0x01, 0x11, 0x06, 'o', 'r', 'd', 'e', 'r', 's',
+	0x14, 0x01, 0x06, 0x00,
+	0x17, 0x05, 'O', 'r', 'd', 'e', 'r', 0x14, 0x01,
+	0x11, 0x08, 'c', 'u', 's', 't', 'o', 'm', 'e', 'r',
+	0x14, 0x01, 0x11, 0x07, 'a', 'd', 'd', 'r', 'e', 's', 's',
+	0x11, 0x0A, 'M', 'a',
+)
+
+func TestDecodeErrorPath(t *testing.T) {
+	_, err := igbinary.Decode(truncatedOrder)
+	var de *igbinary.DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected *DecodeError, got %v", err)
+	}
+	if got := de.PathString(); got != "$.orders[0].customer.address" {
+		t.Errorf("expected path $.orders[0].customer.address, got %s", got)
+	}
+	if len(de.Path) != 4 || de.Path[1].Key != igbinary.IntKey(0) || de.Path[2].Class != "Order" {
+		t.Errorf("unexpected path elements %+v", de.Path)
+	}
+	if de.Code != 0x11 || de.CodePos != 48 {
+		t.Errorf("expected code 0x11 at 48, got 0x%02x at %d", de.Code, de.CodePos)
+	}
+	expected := "igbinary: $.orders[0].customer.address: unexpected end of data at pos 50: need 10 bytes, have 2"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestDecodeErrorPathSkipped(t *testing.T) {
+	_, err := igbinary.DecodeLazy(truncatedOrder)
+	var de *igbinary.DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected *DecodeError, got %v", err)
+	}
+	if got := de.PathString(); got != "$.orders[0].customer.address" {
+		t.Errorf("expected path $.orders[0].customer.address, got %s", got)
+	}
+}
+
+func TestDecodeErrorRootHasNoCode(t *testing.T) {
+	_, err := igbinary.Decode(makePayload(0x14, 0x01))
+	var de *igbinary.DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected *DecodeError, got %v", err)
+	}
+	if len(de.Path) != 0 || de.Code != 0x14 || de.CodePos != 4 {
+		t.Errorf("unexpected error %+v", de)
+	}
+
+	_, err = igbinary.Decode([]byte{0x00})
+	if !errors.As(err, &de) || de.CodePos != -1 {
+		t.Errorf("expected CodePos -1, got %+v", de)
+	}
+}
+
 // --- Reference resolution tests ---
 
 // TestDecodeArrayRefResolvesSharedArray mirrors the PHP promotion_pages scenario:
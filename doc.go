@@ -1,4 +1,5 @@
-// Package igbinary provides a pure Go decoder for PHP's igbinary serialization format.
+// Package igbinary provides a pure Go decoder and encoder for PHP's igbinary
+// serialization format.
 //
 // igbinary is a compact binary serializer for PHP values that replaces PHP's standard
 // serialize() with a faster, smaller binary representation. It is commonly used with
@@ -20,21 +21,151 @@
 //   - PHP NULL        -> nil
 //   - PHP object      -> map[string]any  (class name stored under "__class" key)
 //
+// Go maps do not keep the order of PHP arrays. Decoders created with
+// [WithOrderedMaps] return arrays and objects as *[OrderedMap] instead, which
+// preserves it through [NormalizeArrays], JSON marshaling and [Encode].
+// Likewise, [WithKeyKinds] keeps integer and string keys apart by decoding to
+// map[[Key]]any.
+//
+// Strings are copied out of the input by default. [WithZeroCopyStrings]
+// returns strings that share memory with the input instead, and
+// [WithByteStrings] returns string values as []byte subslices of it; with
+// either option the input must stay alive and unmodified while the results
+// are in use. An [InternPool] passed with [WithInternPool] shares the
+// allocations of repeated keys and class names across decodes.
+//
 // # Quick Start
 //
 //	data := []byte{0x00, 0x00, 0x00, 0x02, 0x06, 0x2a} // igbinary-encoded int(42)
 //	val, err := igbinary.Decode(data)
 //	// val == int64(42)
 //
+// Malformed data is reported as a *[DecodeError] wrapping one of the
+// package's sentinel errors. Its Path locates the failing value inside the
+// tree, and the error message starts with it:
+//
+//	igbinary: $.orders[12].customer.address: unexpected end of data at pos 181
+//
+// [Decoder.DecodePartial] salvages truncated or corrupt payloads: it returns
+// the tree decoded so far, with an *[Unreadable] in place of each failed
+// value, together with all the errors it met.
+//
+// # Decoding into Go Types
+//
+// [Unmarshal] and [Decoder.DecodeInto] assign decoded values directly to Go
+// structs, slices and typed maps, matching struct fields by their igbinary tag:
+//
+//	type User struct {
+//	    ID   int64    `igbinary:"id"`
+//	    Tags []string `igbinary:"tags,omitempty"`
+//	}
+//
+//	var u User
+//	err := igbinary.Unmarshal(data, &u)
+//
+// Types that need a custom PHP representation implement [Marshaler] and
+// [Unmarshaler], which Marshal and DecodeInto call instead of reflection.
+//
+// Objects of known PHP classes can be decoded straight into Go types with
+// [Decoder.RegisterClass]:
+//
+//	dec := igbinary.NewDecoder()
+//	dec.RegisterClass(`App\Entity\Order`, Order{})
+//
+// PHP DateTime and DateTimeZone objects fill time.Time and *time.Location
+// fields, and [WithDateTimes] decodes them, Carbon dates and DateInterval
+// objects into those types anywhere in the tree. [Encode] writes time.Time
+// as a DateTimeImmutable. [WithSPL] replaces ArrayObject, SplObjectStorage,
+// SplFixedArray and SPL list objects by the data they hold.
+//
+// # Value Trees
+//
+// [Decoder.DecodeValue] returns a [Value] tree instead of plain Go values.
+// Its nodes report their [Kind] and offer typed accessors, and the tree keeps
+// key kinds, order, class names and back-references, so it can be encoded
+// again unchanged:
+//
+//	v, err := igbinary.DecodeValue(data)
+//	name := v.Get("customer").Get("name").Str()
+//	for _, e := range v.Get("items").Entries() {
+//	    fmt.Println(e.Key, e.Value.Kind())
+//	}
+//
+// PHP references (&$var) become [Ref] values holding a *[Reference] with
+// Simple set, and every slot aliasing the same variable holds the same
+// pointer. [Decoder.Decode] shares the referenced value between those slots
+// instead, unless the decoder was created with [WithReferences].
+//
+// # Reading Single Fields
+//
+// [Get] decodes only the value at a path of keys, skipping everything else
+// at the byte level:
+//
+//	id, err := igbinary.Get(data, "user", "id") // $data['user']['id']
+//
+// Paths used repeatedly can be compiled with [NewPath] or [ParsePath].
+// [DecodeLazy] returns a [Value] tree whose arrays and objects are decoded
+// only when first accessed, for handlers that read several fields.
+//
+// # Streaming
+//
+// A [Tokenizer] reads a payload token by token, from a byte slice or an
+// [io.Reader], so large arrays can be processed one row at a time without
+// decoding the whole tree first. [Tokenizer.Decode] decodes the value at the
+// current position.
+//
+// A [StreamDecoder] reads a sequence of documents from an [io.Reader], either
+// concatenated or each preceded by its length, buffering one document at a
+// time:
+//
+//	sd := igbinary.NewStreamDecoder(f)
+//	for sd.More() {
+//	    v, err := sd.Decode()
+//	    ...
+//	}
+//
 // # Decoder Options
 //
 // For advanced usage, create a [Decoder] with options:
 //
 //	dec := igbinary.NewDecoder(
-//	    igbinary.WithStrictMode(true),
+//	    igbinary.WithOrderedMaps(),
 //	)
 //	val, err := dec.Decode(data)
 //
+// Objects stored through PHP's Serializable interface decode to their class
+// name and raw data. [WithDecodeSerialized] also decodes that data when it
+// is PHP serialize() or igbinary output, as most serialize() methods
+// return; [Value.Unserialized] returns it from a [Value] tree.
+//
+// [WithLargeStrings] and [WithLargeStringSink] hand string values above a
+// size threshold to a callback or an [io.Writer] instead of decoding them,
+// leaving a *[LargeString] placeholder in the tree.
+//
+// [Decoder.DecodeAt] reads a payload embedded at an offset in a larger frame
+// and returns the number of bytes it took. [WithoutHeader] accepts payloads
+// whose 4-byte header was stripped, and [WithRejectTrailingData] reports
+// bytes after the root value as [ErrTrailingData] instead of ignoring them.
+//
+// # Untrusted Input
+//
+// A few bytes of igbinary can declare arrays of billions of entries or nest
+// arrays without end. The decoder never preallocates more than the rest of
+// the input can fill and limits nesting to [DefaultMaxDepth]; decoders for
+// untrusted payloads should also bound the work they do:
+//
+//	dec := igbinary.NewDecoder(
+//	    igbinary.WithMaxDepth(64),
+//	    igbinary.WithMaxElements(100_000),
+//	    igbinary.WithMaxStringLength(1<<20),
+//	    igbinary.WithMaxTotalBytes(64<<20),
+//	)
+//
+// Exceeding a limit returns a [DecodeError] wrapping [ErrMaxDepthExceeded],
+// [ErrMaxElementsExceeded], [ErrMaxStringLengthExceeded] or
+// [ErrMaxTotalBytesExceeded]. Tokenizers created with [Decoder.NewTokenizer]
+// apply the decoder's depth limit to [Tokenizer.Decode].
+//
 // # Array Normalization
 //
 // PHP does not distinguish indexed arrays from associative arrays at the igbinary
@@ -53,6 +184,26 @@
 //	dec := igbinary.NewDecoder(igbinary.WithNormalizeArrays())
 //	val, _ := dec.Decode(data) // sequential maps are already []any
 //
+// A [NormalizeOption] adjusts which arrays become lists: [KeepEmptyObjectsAt],
+// [AllowSparseLists], [RequireIntKeys] and [MaxListLength]. Pass them to
+// either function:
+//
+//	dec := igbinary.NewDecoder(igbinary.WithNormalizeArrays(igbinary.AllowSparseLists()))
+//
+// NormalizeArrays modifies the maps and slices it keeps; [NormalizeArraysCopy]
+// leaves its input untouched. Both handle the cyclic trees back-references
+// can produce, replacing a container inside itself with a *[CycleRef].
+//
+// # Encoding
+//
+// [Encode] writes Go values back into igbinary so that PHP's
+// igbinary_unserialize() can read them. Maps become PHP arrays, slices become
+// PHP arrays with keys 0..N-1, and maps carrying a "__class" entry become PHP
+// objects. Repeated strings and class names are deduplicated through the
+// string table exactly as PHP's own serializer does:
+//
+//	data, err := igbinary.Encode(map[string]any{"id": 42, "tags": []string{"a", "b"}})
+//
 // # Sub-packages
 //
 // The [github.com/RezaKargar/go-igbinary/memcached] sub-package provides a full
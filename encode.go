@@ -0,0 +1,910 @@
+package igbinary
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Encode encodes a Go value into igbinary format (version 2).
+//
+// The returned bytes include the 4-byte igbinary header and can be read by
+// PHP's igbinary_unserialize().
+//
+// This is a convenience wrapper around [Encoder.Encode] using default options.
+func Encode(v any) ([]byte, error) {
+	return defaultEncoder.Encode(v)
+}
+
+// defaultEncoder is the package-level encoder with default options.
+var defaultEncoder = NewEncoder()
+
+// EncoderOption configures an [Encoder].
+type EncoderOption func(*Encoder)
+
+// WithCompactStrings controls string deduplication, mirroring PHP's
+// igbinary.compact_strings setting. When enabled (the default), repeated
+// strings are written as back-references into the string table instead of
+// being repeated in full. Class names are always deduplicated.
+func WithCompactStrings(compact bool) EncoderOption {
+	return func(e *Encoder) {
+		e.compactStrings = compact
+	}
+}
+
+// Encoder encodes Go values into igbinary-serialized binary data.
+//
+// An Encoder is safe for concurrent use: each call to [Encoder.Encode] creates
+// its own internal state. The Encoder itself only holds configuration.
+//
+// Go values are encoded as follows:
+//
+//   - nil, nil pointers, nil maps and nil slices -> PHP NULL
+//   - bool                                       -> PHP boolean
+//   - signed and unsigned integers               -> PHP integer
+//   - float32, float64                           -> PHP float
+//   - string, []byte                             -> PHP string
+//   - slices and arrays                          -> PHP array with keys 0..N-1
+//   - maps with string or integer keys           -> PHP array
+//   - map[string]any with a "__class" entry      -> PHP object
+//   - *OrderedMap                                -> PHP array or object, in order
+//   - map[Key]any                                -> PHP array or object, key kinds kept
+//   - *Reference                                 -> back-reference to an earlier value
+//   - Value                                      -> the PHP value it holds, as decoded
+//   - time.Time, *time.Location                  -> PHP DateTimeImmutable, DateTimeZone
+//   - structs                                    -> PHP array or object (see [Marshal])
+//
+// Map keys that are canonical decimal integers ("0", "42", "-7") are written
+// as integer keys, just as PHP stores them. Map entries are written with
+// integer keys first in ascending order, followed by string keys in byte
+// order, so the output is deterministic.
+//
+// A map[string]any produced by [Decode] for a Serializable object (holding
+// both "__class" and "__serialized_raw") is written back as a serialized
+// object.
+type Encoder struct {
+	compactStrings bool
+
+	// w is the state of the encode operation in progress. It is only set on
+	// the Encoder handed to [Marshaler] implementations.
+	w *writer
+}
+
+// NewEncoder creates a new Encoder with the given options.
+//
+//	enc := igbinary.NewEncoder(
+//	    igbinary.WithCompactStrings(false),
+//	)
+func NewEncoder(opts ...EncoderOption) *Encoder {
+	e := &Encoder{compactStrings: true}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Encode encodes v into igbinary format, including the 4-byte header.
+func (e *Encoder) Encode(v any) ([]byte, error) {
+	enc := &Encoder{compactStrings: e.compactStrings}
+	w := &writer{
+		buf:     make([]byte, 0, 64),
+		strings: make(map[string]int),
+		compact: e.compactStrings,
+		enc:     enc,
+	}
+	enc.w = w
+	w.buf = append(w.buf, 0x00, 0x00, 0x00, FormatVersion)
+
+	if err := w.encodeValue(v); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+// --- Marshaler write methods ---
+//
+// The methods below append to the payload being encoded and may only be
+// called on the Encoder passed to [Marshaler.MarshalIgbinary]. The first
+// error they encounter is kept and returned once MarshalIgbinary returns.
+
+// EncodeValue writes v using the same rules as [Encoder.Encode].
+func (e *Encoder) EncodeValue(v any) error {
+	w := e.state()
+	if w.err != nil {
+		return w.err
+	}
+	w.fail(w.encodeValue(v))
+	return w.err
+}
+
+// WriteNull writes PHP NULL.
+func (e *Encoder) WriteNull() {
+	e.state().writeNil()
+}
+
+// WriteBool writes a PHP boolean.
+func (e *Encoder) WriteBool(b bool) {
+	e.state().writeBool(b)
+}
+
+// WriteInt writes a PHP integer. It is also used for integer array keys.
+func (e *Encoder) WriteInt(i int64) {
+	e.state().writeInt(i)
+}
+
+// WriteFloat writes a PHP float.
+func (e *Encoder) WriteFloat(f float64) {
+	e.state().writeFloat(f)
+}
+
+// WriteString writes a PHP string, deduplicated through the string table.
+func (e *Encoder) WriteString(s string) {
+	w := e.state()
+	w.fail(w.writeString(s))
+}
+
+// WriteKey writes a string array key, converting canonical decimal integers
+// ("0", "42") to integer keys the way PHP does.
+func (e *Encoder) WriteKey(key string) {
+	w := e.state()
+	w.fail(w.writeKey(key))
+}
+
+// WriteArrayHeader starts a PHP array of n entries. It must be followed by
+// exactly n key/value pairs, each written as a key (WriteKey or WriteInt)
+// and a value.
+func (e *Encoder) WriteArrayHeader(n int) {
+	e.state().writeArrayHeader(n)
+}
+
+// WriteObjectHeader starts a PHP object of the given class with n properties.
+// It must be followed by exactly n property name/value pairs.
+func (e *Encoder) WriteObjectHeader(class string, n int) {
+	w := e.state()
+	w.fail(w.writeObjectHeader(class, n))
+}
+
+// state returns the encode operation in progress, panicking when the Encoder
+// is a configuration-only Encoder obtained from [NewEncoder].
+func (e *Encoder) state() *writer {
+	if e.w == nil {
+		panic("igbinary: Encoder write methods may only be called from MarshalIgbinary")
+	}
+	return e.w
+}
+
+// maxEncodeDepth bounds the nesting depth of encoded values so that cyclic
+// slices and pointers fail with an error instead of overflowing the stack.
+const maxEncodeDepth = 10000
+
+// writer holds the mutable state for a single encode operation.
+type writer struct {
+	buf      []byte
+	strings  map[string]int     // string deduplication table (first ID of each string)
+	nstrings int                // number of string table slots used so far
+	nvalues  int                // number of compound values (arrays and objects) written
+	active   map[valueKey]int   // arrays currently being written, by value ID
+	arrays   map[valueKey]int   // arrays written so far, by value ID
+	objects  map[valueKey]int   // objects written so far, by value ID
+	refs     map[*Reference]int // simple references written so far, by value ID
+	depth    int
+	compact  bool
+	tree     bool     // writing a Value tree, whose *OrderedMap nodes are arrays
+	enc      *Encoder // Encoder handed to Marshaler implementations
+	err      error    // first error from a Marshaler write method
+}
+
+// valueKey identifies a map or pointer written as a PHP array or object. A
+// struct and its first field share an address, so the type is part of the
+// identity.
+type valueKey struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// keyOf returns the identity of the map or pointer rv.
+func keyOf(rv reflect.Value) valueKey {
+	return valueKey{typ: rv.Type(), ptr: rv.Pointer()}
+}
+
+// fail records err as the write error unless one is already recorded.
+func (w *writer) fail(err error) {
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// --- Low-level write primitives ---
+
+func (w *writer) writeUint16(v uint16) {
+	w.buf = append(w.buf, byte(v>>8), byte(v))
+}
+
+func (w *writer) writeUint32(v uint32) {
+	w.buf = append(w.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (w *writer) writeUint64(v uint64) {
+	w.buf = append(w.buf, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// writeSized writes one of three consecutive type codes (8, 16 or 32-bit
+// variants starting at code8) followed by n in the smallest width that fits.
+func (w *writer) writeSized(code8 byte, n int) {
+	switch {
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, code8, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, code8+1)
+		w.writeUint16(uint16(n))
+	default:
+		w.buf = append(w.buf, code8+2)
+		w.writeUint32(uint32(n))
+	}
+}
+
+// --- Scalar encoding ---
+
+func (w *writer) writeNil() {
+	w.buf = append(w.buf, TypeNil)
+}
+
+func (w *writer) writeBool(b bool) {
+	if b {
+		w.buf = append(w.buf, TypeBoolTrue)
+	} else {
+		w.buf = append(w.buf, TypeBoolFalse)
+	}
+}
+
+func (w *writer) writeInt(v int64) {
+	if v >= 0 {
+		w.writeMagnitude(uint64(v), TypePosInt8, TypePosInt16, TypePosInt32, TypePosInt64)
+		return
+	}
+	// -v overflows for math.MinInt64, but the uint64 conversion still yields
+	// the correct magnitude (1 << 63).
+	w.writeMagnitude(uint64(-v), TypeNegInt8, TypeNegInt16, TypeNegInt32, TypeNegInt64)
+}
+
+func (w *writer) writeMagnitude(u uint64, c8, c16, c32, c64 byte) {
+	switch {
+	case u <= math.MaxUint8:
+		w.buf = append(w.buf, c8, byte(u))
+	case u <= math.MaxUint16:
+		w.buf = append(w.buf, c16)
+		w.writeUint16(uint16(u))
+	case u <= math.MaxUint32:
+		w.buf = append(w.buf, c32)
+		w.writeUint32(uint32(u))
+	default:
+		w.buf = append(w.buf, c64)
+		w.writeUint64(u)
+	}
+}
+
+func (w *writer) writeUint(u uint64) error {
+	if u > math.MaxInt64 {
+		return fmt.Errorf("%w: %d overflows PHP integer", ErrUnsupportedValue, u)
+	}
+	w.writeInt(int64(u))
+	return nil
+}
+
+func (w *writer) writeFloat(f float64) {
+	w.buf = append(w.buf, TypeDouble)
+	w.writeUint64(math.Float64bits(f))
+}
+
+// writeString writes a string value or key, emitting a back-reference when
+// the string was seen before and compact strings are enabled.
+func (w *writer) writeString(s string) error {
+	if s == "" {
+		// Empty strings have their own type code and never occupy a slot
+		// in the string table.
+		w.buf = append(w.buf, TypeStringEmpty)
+		return nil
+	}
+	if id, ok := w.strings[s]; ok && w.compact {
+		w.writeSized(TypeStringID8, id)
+		return nil
+	}
+	if uint64(len(s)) > math.MaxUint32 {
+		return fmt.Errorf("%w: string of %d bytes exceeds 32-bit length", ErrUnsupportedValue, len(s))
+	}
+	w.writeSized(TypeString8, len(s))
+	w.buf = append(w.buf, s...)
+	w.registerString(s)
+	return nil
+}
+
+// registerString records s in the string table, keeping the first ID when the
+// same string is registered more than once. Every registration occupies a
+// slot because the decoder appends each new string unconditionally.
+func (w *writer) registerString(s string) {
+	if _, ok := w.strings[s]; !ok {
+		w.strings[s] = w.nstrings
+	}
+	w.nstrings++
+}
+
+// writeKey writes a string array key, converting canonical decimal integers
+// to integer keys the way PHP does.
+func (w *writer) writeKey(s string) error {
+	if i, ok := parseIntKey(s); ok {
+		w.writeInt(i)
+		return nil
+	}
+	return w.writeString(s)
+}
+
+// writeEntryKey writes the key of e as an integer or a string, as recorded
+// in e.IsInt.
+func (w *writer) writeEntryKey(e Entry) error {
+	if !e.IsInt {
+		return w.writeString(e.Key)
+	}
+	i, err := strconv.ParseInt(e.Key, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: integer key %q", ErrUnsupportedValue, e.Key)
+	}
+	w.writeInt(i)
+	return nil
+}
+
+// parseIntKey reports whether s is a canonical decimal integer that PHP would
+// store as an integer array key ("0", "42", "-7", but not "007" or "+1").
+func parseIntKey(s string) (int64, bool) {
+	if s == "" || len(s) > 20 {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(i, 10) != s {
+		return 0, false
+	}
+	return i, true
+}
+
+// --- Compound encoding ---
+
+// writeArrayHeader writes an array type code and element count, and
+// registers the array in the compound value table.
+func (w *writer) writeArrayHeader(n int) {
+	w.writeSized(TypeArray8, n)
+	w.nvalues++
+}
+
+// writeObjectHeader writes an object type code with its class name (inline or
+// as a string table back-reference) followed by the property count, and
+// registers the object in the compound value table.
+func (w *writer) writeObjectHeader(class string, n int) error {
+	if id, ok := w.strings[class]; ok {
+		w.writeSized(TypeObjectID8, id)
+	} else {
+		if uint64(len(class)) > math.MaxUint32 {
+			return fmt.Errorf("%w: class name of %d bytes exceeds 32-bit length", ErrUnsupportedValue, len(class))
+		}
+		w.writeSized(TypeObject8, len(class))
+		w.buf = append(w.buf, class...)
+		w.registerString(class)
+	}
+	w.writeSized(TypeArray8, n)
+	w.nvalues++
+	return nil
+}
+
+// writeSerialized writes an object whose PHP class implements Serializable,
+// with raw holding the output of its serialize() method.
+func (w *writer) writeSerialized(class, raw string) error {
+	if uint64(len(class)) > math.MaxUint32 || uint64(len(raw)) > math.MaxUint32 {
+		return fmt.Errorf("%w: serialized object exceeds 32-bit length", ErrUnsupportedValue)
+	}
+	// The class name of a serialized object is always written inline and
+	// registered again, matching how the decoder consumes it.
+	w.writeSized(TypeObjectSer8, len(class))
+	w.buf = append(w.buf, class...)
+	w.registerString(class)
+	w.writeSized(TypeString8, len(raw))
+	w.buf = append(w.buf, raw...)
+	w.nvalues++
+	return nil
+}
+
+// enter increments the nesting depth, failing once it exceeds maxEncodeDepth.
+func (w *writer) enter() error {
+	w.depth++
+	if w.depth > maxEncodeDepth {
+		return fmt.Errorf("%w: exceeds maximum nesting depth of %d (cyclic value?)",
+			ErrUnsupportedValue, maxEncodeDepth)
+	}
+	return nil
+}
+
+func (w *writer) leave() {
+	w.depth--
+}
+
+// --- Value encoding ---
+
+func (w *writer) encodeValue(v any) error {
+	switch val := v.(type) {
+	case nil:
+		w.writeNil()
+	case Marshaler:
+		return w.encodeMarshaler(val)
+	case Value:
+		return w.encodeTree(val)
+	case bool:
+		w.writeBool(val)
+	case int:
+		w.writeInt(int64(val))
+	case int8:
+		w.writeInt(int64(val))
+	case int16:
+		w.writeInt(int64(val))
+	case int32:
+		w.writeInt(int64(val))
+	case int64:
+		w.writeInt(val)
+	case uint:
+		return w.writeUint(uint64(val))
+	case uint8:
+		w.writeInt(int64(val))
+	case uint16:
+		w.writeInt(int64(val))
+	case uint32:
+		w.writeInt(int64(val))
+	case uint64:
+		return w.writeUint(val)
+	case float32:
+		w.writeFloat(float64(val))
+	case float64:
+		w.writeFloat(val)
+	case string:
+		return w.writeString(val)
+	case []byte:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return w.writeString(string(val))
+	case []any:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return w.encodeList(len(val), func(i int) any { return val[i] })
+	case map[string]any:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return w.encodeStringMap(val)
+	case *OrderedMap:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return w.encodeOrderedMap(val)
+	case map[Key]any:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return w.encodeKeyMap(val)
+	case *Reference:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return w.encodeReference(val)
+	case *object:
+		return w.encodeObject(val)
+	case *LargeString:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return fmt.Errorf("%w: *LargeString placeholder for the string at %s", ErrUnsupportedValue, val.Path)
+	case time.Time:
+		return w.encodeTime(val)
+	case *time.Time:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return w.encodeTime(*val)
+	case *time.Location:
+		if val == nil {
+			w.writeNil()
+			return nil
+		}
+		return w.encodeLocation(val)
+	default:
+		return w.encodeReflect(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+// encodeList writes n elements as a PHP array with keys 0..n-1.
+func (w *writer) encodeList(n int, elem func(i int) any) error {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.leave()
+
+	w.writeArrayHeader(n)
+	for i := 0; i < n; i++ {
+		w.writeInt(int64(i))
+		if err := w.encodeValue(elem(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeStringMap writes a map[string]any as a PHP array, or as a PHP object
+// when it carries a class name under [ClassKey].
+func (w *writer) encodeStringMap(m map[string]any) error {
+	class, _ := m[ClassKey].(string)
+	raw, isSerialized := m[SerializedDataKey].(string)
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if class != "" && k == ClassKey {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	entries := make([]Entry, len(keys))
+	for i, k := range keys {
+		_, isInt := parseIntKey(k)
+		entries[i] = Entry{Key: k, Value: m[k], IsInt: isInt}
+	}
+	return w.encodeEntries(keyOf(reflect.ValueOf(m)), class, raw, isSerialized, entries)
+}
+
+// encodeKeyMap writes a map[Key]any, keeping the kind of every key.
+func (w *writer) encodeKeyMap(m map[Key]any) error {
+	classKey := StringKey(ClassKey)
+	class, _ := m[classKey].(string)
+	raw, isSerialized := m[StringKey(SerializedDataKey)].(string)
+
+	keys := make([]Key, 0, len(m))
+	for k := range m {
+		if class != "" && k == classKey {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sortKeyKinds(keys)
+
+	entries := make([]Entry, len(keys))
+	for i, k := range keys {
+		entries[i] = Entry{Key: k.String(), Value: m[k], IsInt: k.IsInt}
+	}
+	return w.encodeEntries(keyOf(reflect.ValueOf(m)), class, raw, isSerialized, entries)
+}
+
+// encodeOrderedMap writes an *OrderedMap in insertion order, as a PHP object
+// when it carries a class name under [ClassKey] outside a Value tree. Entries
+// without a recorded key kind are converted like map[string]any keys.
+func (w *writer) encodeOrderedMap(m *OrderedMap) error {
+	var class, raw string
+	var isSerialized bool
+	if !w.tree {
+		if c, ok := m.Get(ClassKey); ok {
+			class, _ = c.(string)
+		}
+		if r, ok := m.Get(SerializedDataKey); ok {
+			raw, isSerialized = r.(string)
+		}
+	}
+
+	entries := make([]Entry, 0, m.Len())
+	for _, e := range m.Entries() {
+		if class != "" && e.Key == ClassKey {
+			continue
+		}
+		if !e.IsInt {
+			_, e.IsInt = parseIntKey(e.Key)
+		}
+		entries = append(entries, e)
+	}
+	return w.encodeEntries(keyOf(reflect.ValueOf(m)), class, raw, isSerialized, entries)
+}
+
+// encodeTree writes v, keeping objects and arrays of a Value tree apart.
+func (w *writer) encodeTree(v Value) error {
+	tree := w.tree
+	w.tree = v.tree
+	defer func() { w.tree = tree }()
+	if v.tree {
+		return w.encodeValue(v.node())
+	}
+	return w.encodeValue(v.Interface())
+}
+
+// encodeObject writes an object of a Value tree. Its properties always
+// record their key kind.
+func (w *writer) encodeObject(o *object) error {
+	var entries []Entry
+	if o.props != nil {
+		entries = o.props.Entries()
+	}
+	return w.encodeEntries(keyOf(reflect.ValueOf(o)), o.class, o.raw, o.props == nil, entries)
+}
+
+// encodeEntries writes the entries of a decoded PHP array or object held in
+// the container identified by key. A non-empty class makes it an object, or a
+// serialized object when isSerialized is set. Keys are written with the kind
+// recorded in [Entry.IsInt].
+func (w *writer) encodeEntries(key valueKey, class, raw string, isSerialized bool, entries []Entry) error {
+	isObject := class != ""
+
+	// PHP objects have identity, so an object seen before is written as a
+	// back-reference. An array that is already being written can only be
+	// reached again through a cycle; emit a back-reference to it instead of
+	// recursing forever.
+	if id, ok := w.objects[key]; ok && isObject {
+		w.writeSized(TypeObjectRef8, id)
+		return nil
+	}
+	if isObject && isSerialized {
+		if err := w.writeSerialized(class, raw); err != nil {
+			return err
+		}
+		w.markObject(key)
+		return nil
+	}
+	if id, ok := w.active[key]; ok && !isObject {
+		w.writeSized(TypeArrayRef8, id)
+		return nil
+	}
+
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.leave()
+
+	if isObject {
+		if err := w.writeObjectHeader(class, len(entries)); err != nil {
+			return err
+		}
+		w.markObject(key)
+	} else {
+		w.writeArrayHeader(len(entries))
+		w.markActive(key)
+		defer delete(w.active, key)
+	}
+
+	for _, e := range entries {
+		if err := w.writeEntryKey(e); err != nil {
+			return err
+		}
+		if err := w.encodeValue(e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markActive records the array identified by key as the compound value just
+// registered.
+func (w *writer) markActive(key valueKey) {
+	if w.active == nil {
+		w.active = make(map[valueKey]int)
+		w.arrays = make(map[valueKey]int)
+	}
+	w.active[key] = w.nvalues - 1
+	w.arrays[key] = w.nvalues - 1
+}
+
+// encodeReference writes ref as a back-reference to its target, which must
+// already have been written, or as a PHP reference for simple references.
+func (w *writer) encodeReference(ref *Reference) error {
+	if ref.Simple {
+		return w.encodeSimpleRef(ref)
+	}
+	rv := reflect.ValueOf(ref.Target)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Pointer:
+		table, code := w.arrays, TypeArrayRef8
+		if ref.Object {
+			table, code = w.objects, TypeObjectRef8
+		}
+		if id, ok := table[keyOf(rv)]; ok {
+			w.writeSized(code, id)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: reference to a %T that was not written before it", ErrUnsupportedValue, ref.Target)
+}
+
+// encodeSimpleRef writes the first occurrence of the PHP reference ref as a
+// TypeSimpleRef code followed by its target, and later ones as
+// back-references to the values table slot it took.
+func (w *writer) encodeSimpleRef(ref *Reference) error {
+	if id, ok := w.refs[ref]; ok {
+		w.writeSized(TypeArrayRef8, id)
+		return nil
+	}
+	w.buf = append(w.buf, TypeSimpleRef)
+	start, nvalues := len(w.buf), w.nvalues
+	if err := w.encodeValue(ref.Target); err != nil {
+		return err
+	}
+
+	// Find the slot the reference took, as the decoder does.
+	id := nvalues
+	switch code := w.buf[start]; {
+	case backRefBase(code) != 0:
+		id = sizedAt(w.buf[start:], backRefBase(code))
+	case !isCompoundCode(code) && code != TypeSimpleRef:
+		w.nvalues++
+	}
+	if w.refs == nil {
+		w.refs = make(map[*Reference]int)
+	}
+	w.refs[ref] = id
+	return nil
+}
+
+// sizedAt returns the length or ID that follows the type code at b[0], from
+// the type code group with 8-bit variant base.
+func sizedAt(b []byte, base byte) int {
+	switch b[0] - base {
+	case 0:
+		return int(b[1])
+	case 1:
+		return int(b[1])<<8 | int(b[2])
+	default:
+		return int(b[1])<<24 | int(b[2])<<16 | int(b[3])<<8 | int(b[4])
+	}
+}
+
+// markObject records the object identified by key as the compound value just
+// registered.
+func (w *writer) markObject(key valueKey) {
+	if w.objects == nil {
+		w.objects = make(map[valueKey]int)
+	}
+	w.objects[key] = w.nvalues - 1
+}
+
+// sortKeys orders map keys the way the encoder writes them: canonical integer
+// keys first in ascending numeric order, then string keys in byte order.
+func sortKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, aInt := parseIntKey(keys[i])
+		b, bInt := parseIntKey(keys[j])
+		switch {
+		case aInt && bInt:
+			return a < b
+		case aInt != bInt:
+			return aInt
+		default:
+			return keys[i] < keys[j]
+		}
+	})
+}
+
+// --- Reflection-based encoding ---
+
+func (w *writer) encodeReflect(rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Invalid:
+		w.writeNil()
+	case reflect.Bool:
+		w.writeBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.writeInt(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return w.writeUint(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		w.writeFloat(rv.Float())
+	case reflect.String:
+		return w.writeString(rv.String())
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		if rv.Kind() == reflect.Pointer && rv.Elem().Kind() == reflect.Struct {
+			return w.encodeStructPointer(rv, "")
+		}
+		if err := w.enter(); err != nil {
+			return err
+		}
+		defer w.leave()
+		return w.encodeValue(rv.Elem().Interface())
+	case reflect.Slice:
+		if rv.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return w.writeString(string(rv.Bytes()))
+		}
+		return w.encodeList(rv.Len(), func(i int) any { return rv.Index(i).Interface() })
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return w.writeString(string(b))
+		}
+		return w.encodeList(rv.Len(), func(i int) any { return rv.Index(i).Interface() })
+	case reflect.Map:
+		if rv.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		return w.encodeReflectMap(rv)
+	case reflect.Struct:
+		return w.encodeStruct(rv, "", valueKey{})
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, rv.Type())
+	}
+	return nil
+}
+
+// encodeReflectMap writes a map with string or integer keys as a PHP array.
+func (w *writer) encodeReflectMap(rv reflect.Value) error {
+	key := keyOf(rv)
+	if id, ok := w.active[key]; ok {
+		w.writeSized(TypeArrayRef8, id)
+		return nil
+	}
+
+	byKey := make(map[string]reflect.Value, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		k := iter.Key()
+		var key string
+		switch k.Kind() {
+		case reflect.String:
+			key = k.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key = strconv.FormatInt(k.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if k.Uint() > math.MaxInt64 {
+				return fmt.Errorf("%w: map key %d overflows PHP integer", ErrUnsupportedValue, k.Uint())
+			}
+			key = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return fmt.Errorf("%w: map key type %s", ErrUnsupportedType, k.Type())
+		}
+		byKey[key] = iter.Value()
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.leave()
+
+	w.writeArrayHeader(len(keys))
+	w.markActive(key)
+	defer delete(w.active, key)
+
+	for _, k := range keys {
+		if err := w.writeKey(k); err != nil {
+			return err
+		}
+		if err := w.encodeValue(byKey[k].Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// --- Scalars ---
+
+func TestEncodeScalars(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want []byte
+	}{
+		{"nil", nil, makePayload(0x00)},
+		{"false", false, makePayload(0x04)},
+		{"true", true, makePayload(0x05)},
+		{"posint8", 42, makePayload(0x06, 0x2A)},
+		{"posint16", 256, makePayload(0x08, 0x01, 0x00)},
+		{"posint32", 100000, makePayload(0x0A, 0x00, 0x01, 0x86, 0xA0)},
+		{"posint64", int64(1) << 40, makePayload(0x20, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00)},
+		{"negint8", -5, makePayload(0x07, 0x05)},
+		{"negint16", -300, makePayload(0x09, 0x01, 0x2C)},
+		{"negint32", int32(-70000), makePayload(0x0B, 0x00, 0x01, 0x11, 0x70)},
+		{"uint8", uint8(200), makePayload(0x06, 0xC8)},
+		{"double", 1.5, makePayload(0x0C, 0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)},
+		{"empty string", "", makePayload(0x0D)},
+		{"string8", "hello", makePayload(0x11, 0x05, 'h', 'e', 'l', 'l', 'o')},
+		{"bytes", []byte("hi"), makePayload(0x11, 0x02, 'h', 'i')},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := igbinary.Encode(tt.in)
+			assertNoError(t, err)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeIntegerExtremesRoundTrip(t *testing.T) {
+	for _, v := range []int64{math.MaxInt64, math.MinInt64, math.MaxUint32, -math.MaxUint32 - 1} {
+		data, err := igbinary.Encode(v)
+		assertNoError(t, err)
+		val, err := igbinary.Decode(data)
+		assertNoError(t, err)
+		assertEqualInt64(t, val, v)
+	}
+}
+
+func TestEncodeString16(t *testing.T) {
+	s := string(bytes.Repeat([]byte("x"), 300))
+	data, err := igbinary.Encode(s)
+	assertNoError(t, err)
+	if data[4] != igbinary.TypeString16 {
+		t.Fatalf("expected TypeString16, got 0x%02x", data[4])
+	}
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	assertEqualString(t, val, s)
+}
+
+func TestEncodeUint64Overflow(t *testing.T) {
+	_, err := igbinary.Encode(uint64(math.MaxUint64))
+	if !errors.Is(err, igbinary.ErrUnsupportedValue) {
+		t.Errorf("expected ErrUnsupportedValue, got: %v", err)
+	}
+}
+
+func TestEncodeUnsupportedType(t *testing.T) {
+	_, err := igbinary.Encode(make(chan int))
+	if !errors.Is(err, igbinary.ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got: %v", err)
+	}
+}
+
+// --- Arrays ---
+
+func TestEncodeList(t *testing.T) {
+	data, err := igbinary.Encode([]any{"a", int64(2)})
+	assertNoError(t, err)
+	want := makePayload(
+		0x14, 0x02, // array8, 2 entries
+		0x06, 0x00, // key: int 0
+		0x11, 0x01, 'a', // value: "a"
+		0x06, 0x01, // key: int 1
+		0x06, 0x02, // value: int 2
+	)
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestEncodeMapKeyOrderAndIntegerKeys(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{
+		"name": "x",
+		"10":   int64(1),
+		"2":    int64(2),
+		"007":  int64(3), // not canonical, stays a string key
+	})
+	assertNoError(t, err)
+	want := makePayload(
+		0x14, 0x04,
+		0x06, 0x02, 0x06, 0x02, // 2 => 2
+		0x06, 0x0A, 0x06, 0x01, // 10 => 1
+		0x11, 0x03, '0', '0', '7', 0x06, 0x03, // "007" => 3
+		0x11, 0x04, 'n', 'a', 'm', 'e', 0x11, 0x01, 'x', // "name" => "x"
+	)
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestEncodeTypedMapAndSlice(t *testing.T) {
+	data, err := igbinary.Encode(map[int][]string{3: {"a", "b"}})
+	assertNoError(t, err)
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+
+	inner := val.(map[string]any)["3"].(map[string]any)
+	assertEqualString(t, inner["0"], "a")
+	assertEqualString(t, inner["1"], "b")
+}
+
+func TestEncodeNilContainers(t *testing.T) {
+	var m map[string]any
+	var s []int
+	for _, v := range []any{m, s, (*int)(nil)} {
+		data, err := igbinary.Encode(v)
+		assertNoError(t, err)
+		if !bytes.Equal(data, makePayload(0x00)) {
+			t.Errorf("%T: got % x, want NULL", v, data)
+		}
+	}
+}
+
+// --- String deduplication ---
+
+func TestEncodeStringDedup(t *testing.T) {
+	data, err := igbinary.Encode([]any{"hello", "hello"})
+	assertNoError(t, err)
+	want := makePayload(
+		0x14, 0x02,
+		0x06, 0x00, 0x11, 0x05, 'h', 'e', 'l', 'l', 'o', // "hello" (string ID 0)
+		0x06, 0x01, 0x0E, 0x00, // StringID8(0)
+	)
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestEncodeWithoutCompactStrings(t *testing.T) {
+	enc := igbinary.NewEncoder(igbinary.WithCompactStrings(false))
+	data, err := enc.Encode([]any{"hi", "hi"})
+	assertNoError(t, err)
+	want := makePayload(
+		0x14, 0x02,
+		0x06, 0x00, 0x11, 0x02, 'h', 'i',
+		0x06, 0x01, 0x11, 0x02, 'h', 'i',
+	)
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestEncodeEmptyStringDoesNotShiftStringIDs(t *testing.T) {
+	in := map[string]any{"a": "", "b": "x", "c": "x"}
+	data, err := igbinary.Encode(in)
+	assertNoError(t, err)
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	assertEqualString(t, m["a"], "")
+	assertEqualString(t, m["b"], "x")
+	assertEqualString(t, m["c"], "x")
+}
+
+// --- Objects ---
+
+func TestEncodeObjectRoundTrip(t *testing.T) {
+	in := []any{
+		map[string]any{igbinary.ClassKey: "App\\User", "name": "Alice"},
+		map[string]any{igbinary.ClassKey: "App\\User", "name": "Bob"},
+	}
+	data, err := igbinary.Encode(in)
+	assertNoError(t, err)
+
+	// The second object must reference the class name by string ID.
+	if !bytes.Contains(data, []byte{0x1A, 0x00}) {
+		t.Errorf("expected TypeObjectID8 for repeated class, got % x", data)
+	}
+
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	for key, name := range map[string]string{"0": "Alice", "1": "Bob"} {
+		obj := m[key].(map[string]any)
+		assertEqualString(t, obj[igbinary.ClassKey], "App\\User")
+		assertEqualString(t, obj["name"], name)
+	}
+}
+
+func TestEncodeSerializedObjectRoundTrip(t *testing.T) {
+	in := map[string]any{igbinary.ClassKey: "Foo", igbinary.SerializedDataKey: "raw"}
+	data, err := igbinary.Encode(in)
+	assertNoError(t, err)
+	want := makePayload(0x1D, 0x03, 'F', 'o', 'o', 0x11, 0x03, 'r', 'a', 'w')
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+// --- Cycles ---
+
+func TestEncodeCyclicMapUsesArrayRef(t *testing.T) {
+	m := map[string]any{}
+	m["self"] = m
+	data, err := igbinary.Encode(m)
+	assertNoError(t, err)
+	want := makePayload(
+		0x14, 0x01,
+		0x11, 0x04, 's', 'e', 'l', 'f',
+		0x01, 0x00, // ArrayRef8(0)
+	)
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestEncodeCyclicSliceFails(t *testing.T) {
+	s := make([]any, 1)
+	s[0] = s
+	_, err := igbinary.Encode(s)
+	if !errors.Is(err, igbinary.ErrUnsupportedValue) {
+		t.Errorf("expected ErrUnsupportedValue, got: %v", err)
+	}
+}
+
+// --- Decode round trip ---
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	data := makePayload(
+		0x14, 0x03,
+		0x11, 0x02, 'i', 'd', 0x06, 0x07,
+		0x11, 0x04, 't', 'a', 'g', 's', 0x14, 0x02,
+		0x06, 0x00, 0x11, 0x01, 'a',
+		0x06, 0x01, 0x11, 0x01, 'b',
+		0x11, 0x05, 'p', 'r', 'i', 'c', 'e', 0x0C, 0x40, 0x09, 0x21, 0xF9, 0xF0, 0x1B, 0x86, 0x6E,
+	)
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	out, err := igbinary.Encode(val)
+	assertNoError(t, err)
+	again, err := igbinary.Decode(out)
+	assertNoError(t, err)
+
+	m := again.(map[string]any)
+	assertEqualInt64(t, m["id"], 7)
+	assertEqualFloat64(t, m["price"], 3.14159)
+	tags := m["tags"].(map[string]any)
+	assertEqualString(t, tags["0"], "a")
+	assertEqualString(t, tags["1"], "b")
+}
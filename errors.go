@@ -3,6 +3,9 @@ package igbinary
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
 )
 
 // Sentinel errors returned by the decoder.
@@ -42,6 +45,72 @@ var (
 	// ErrValueRefOutOfRange is returned when an array/object back-reference ID
 	// exceeds the number of compound values seen so far.
 	ErrValueRefOutOfRange = errors.New("igbinary: value reference ID out of range")
+
+	// ErrPathNotFound is returned by [Get] when a key of the path does not
+	// exist, or leads into a value that is not an array or object.
+	ErrPathNotFound = errors.New("igbinary: path not found")
+
+	// ErrTrailingData is returned by decoders created with
+	// [WithRejectTrailingData] when data continues after the root value.
+	ErrTrailingData = errors.New("igbinary: trailing data after value")
+
+	// ErrDocumentTooLarge is returned by a [StreamDecoder] when a document
+	// exceeds its maximum document size.
+	ErrDocumentTooLarge = errors.New("igbinary: document too large")
+
+	// ErrMaxDepthExceeded is returned when arrays and objects are nested
+	// deeper than allowed by [WithMaxDepth].
+	ErrMaxDepthExceeded = errors.New("igbinary: maximum nesting depth exceeded")
+
+	// ErrMaxElementsExceeded is returned when a payload declares more array
+	// entries and object properties than allowed by [WithMaxElements].
+	ErrMaxElementsExceeded = errors.New("igbinary: maximum element count exceeded")
+
+	// ErrMaxStringLengthExceeded is returned when a string is longer than
+	// allowed by [WithMaxStringLength].
+	ErrMaxStringLengthExceeded = errors.New("igbinary: maximum string length exceeded")
+
+	// ErrMaxTotalBytesExceeded is returned when decoding would allocate more
+	// than allowed by [WithMaxTotalBytes].
+	ErrMaxTotalBytesExceeded = errors.New("igbinary: maximum total size exceeded")
+)
+
+// Sentinel errors returned when decoding into Go values.
+var (
+	// ErrInvalidTarget is returned when the target passed to [Decoder.DecodeInto]
+	// or [Unmarshal] is not a non-nil pointer.
+	ErrInvalidTarget = errors.New("igbinary: target must be a non-nil pointer")
+
+	// ErrTypeMismatch is returned (wrapped in an [UnmarshalTypeError]) when a
+	// decoded PHP value cannot be assigned to the Go target type.
+	ErrTypeMismatch = errors.New("igbinary: type mismatch")
+
+	// ErrInvalidDateTime is returned when a PHP DateTime or DateTimeZone
+	// object cannot be converted to time.Time or *time.Location, e.g.
+	// because its time zone is unknown.
+	ErrInvalidDateTime = errors.New("igbinary: invalid DateTime")
+
+	// ErrCyclicValue is returned when a PHP array or object that contains
+	// itself through back-references is assigned to a Go type that can only
+	// hold the cycle through a pointer, e.g. a struct field of its own type
+	// in a map or slice rather than behind a pointer.
+	ErrCyclicValue = errors.New("igbinary: cyclic value")
+
+	// ErrUnexportedEmbeddedPointer is returned when a PHP key maps to a
+	// field promoted through a nil pointer to an unexported embedded struct,
+	// which cannot be allocated through reflection.
+	ErrUnexportedEmbeddedPointer = errors.New("igbinary: cannot set embedded pointer to unexported struct")
+)
+
+// Sentinel errors returned by the encoder.
+var (
+	// ErrUnsupportedType is returned when the encoder is given a Go type that
+	// has no igbinary representation (e.g., channels, functions, complex numbers).
+	ErrUnsupportedType = errors.New("igbinary: unsupported type")
+
+	// ErrUnsupportedValue is returned when a value of a supported type cannot be
+	// represented in igbinary (e.g., a uint64 above math.MaxInt64).
+	ErrUnsupportedValue = errors.New("igbinary: unsupported value")
 )
 
 // DecodeError wraps a sentinel error with positional context about where
@@ -53,14 +122,53 @@ type DecodeError struct {
 	Pos int
 	// Detail provides additional context about the error.
 	Detail string
+
+	// Path leads from the root value to the value that failed to decode,
+	// one element per enclosing array entry or object property.
+	Path []PathElem
+	// Code is the type code of the innermost value that failed to decode,
+	// and CodePos its byte offset in the input. CodePos is -1 if the error
+	// occurred before a type code was read.
+	Code    byte
+	CodePos int
+
+	// resumable reports that the input position is still valid after the
+	// failing value, see DecodePartial.
+	resumable bool
+}
+
+// PathElem is one step of a [DecodeError] path: an entry of an array, or a
+// property of an object when Class is set.
+type PathElem struct {
+	Key   Key    // array key or property name
+	Index int    // position of the entry in its array or object
+	Class string // class of the object, "" for arrays
 }
 
-// Error returns a human-readable description of the decode error.
+// Error returns a human-readable description of the decode error, starting
+// with the path of the failing value if it is nested:
+//
+//	igbinary: $.orders[12].customer.address: unexpected end of data at pos 181
 func (e *DecodeError) Error() string {
+	msg := e.Err.Error()
+	if len(e.Path) > 0 {
+		msg = "igbinary: " + e.PathString() + ": " + strings.TrimPrefix(msg, "igbinary: ")
+	}
 	if e.Detail != "" {
-		return fmt.Sprintf("%s at pos %d: %s", e.Err.Error(), e.Pos, e.Detail)
+		return fmt.Sprintf("%s at pos %d: %s", msg, e.Pos, e.Detail)
+	}
+	return fmt.Sprintf("%s at pos %d", msg, e.Pos)
+}
+
+// PathString returns the path of the failing value in the notation accepted
+// by [ParsePath], such as "$.orders[12].customer".
+func (e *DecodeError) PathString() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, elem := range e.Path {
+		writePathKey(&b, elem.Key.String())
 	}
-	return fmt.Sprintf("%s at pos %d", e.Err.Error(), e.Pos)
+	return b.String()
 }
 
 // Unwrap returns the underlying sentinel error, enabling errors.Is() matching.
@@ -70,5 +178,70 @@ func (e *DecodeError) Unwrap() error {
 
 // newError creates a DecodeError with position and optional detail.
 func newError(err error, pos int, detail string) *DecodeError {
-	return &DecodeError{Err: err, Pos: pos, Detail: detail}
+	return &DecodeError{Err: err, Pos: pos, Detail: detail, CodePos: -1}
+}
+
+// withCode records the type code of the innermost failing value in the
+// DecodeError inside err, unless a nested value recorded its own.
+func withCode(err error, code byte, pos int) error {
+	var de *DecodeError
+	if errors.As(err, &de) && de.CodePos < 0 {
+		de.Code, de.CodePos = code, pos
+	}
+	return err
+}
+
+// resumable marks the DecodeError inside err as one the decoder can resume
+// after, because the failing value was read completely.
+func resumable(err error) error {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		de.resumable = true
+	}
+	return err
+}
+
+// withPathElem prepends the array entry or object property whose value
+// failed to decode to the path of the DecodeError inside err.
+func withPathElem(err error, elem PathElem) error {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		de.Path = slices.Insert(de.Path, 0, elem)
+	}
+	return err
+}
+
+// withDetail prefixes the detail of the DecodeError inside err with what
+// was being read.
+func withDetail(err error, what string) error {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		if de.Detail != "" {
+			what += ": " + de.Detail
+		}
+		de.Detail = what
+	}
+	return err
+}
+
+// UnmarshalTypeError describes a PHP value that could not be assigned to a
+// Go value of a specific type.
+type UnmarshalTypeError struct {
+	// Value describes the PHP value, e.g. "string", "array" or "object(App\User)".
+	Value string
+	// Type is the Go type the value could not be assigned to.
+	Type reflect.Type
+	// Path locates the value within the decoded tree, e.g. "$.orders[12].id".
+	Path string
+}
+
+// Error returns a human-readable description of the type mismatch.
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("%s: cannot assign PHP %s to Go %s at %s",
+		ErrTypeMismatch.Error(), e.Value, e.Type, e.Path)
+}
+
+// Unwrap returns [ErrTypeMismatch], enabling errors.Is() matching.
+func (e *UnmarshalTypeError) Unwrap() error {
+	return ErrTypeMismatch
 }
@@ -0,0 +1,228 @@
+package igbinary
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// field describes one Go struct field mapped to a PHP array key or property.
+type field struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	tagged    bool
+	omitEmpty bool
+	class     string // PHP class to encode the field's struct value as
+}
+
+// structFields holds the resolved fields of a struct type.
+type structFields struct {
+	class  string // PHP class from a `_ struct{}` marker field, if any
+	list   []field
+	byName map[string]int // exact name -> index into list
+	byFold map[string]int // lower-cased name -> index into list
+}
+
+// lookup finds the field for a PHP key, preferring an exact match and falling
+// back to a case-insensitive one.
+func (sf *structFields) lookup(name string) (*field, bool) {
+	if i, ok := sf.byName[name]; ok {
+		return &sf.list[i], true
+	}
+	if i, ok := sf.byFold[strings.ToLower(name)]; ok {
+		return &sf.list[i], true
+	}
+	return nil, false
+}
+
+// fieldCache maps reflect.Type to *structFields.
+var fieldCache sync.Map
+
+// cachedFields returns the igbinary field mapping for struct type t.
+func cachedFields(t reflect.Type) *structFields {
+	if sf, ok := fieldCache.Load(t); ok {
+		return sf.(*structFields)
+	}
+	sf, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return sf.(*structFields)
+}
+
+// typeFields resolves the fields of struct type t, following the same rules
+// as encoding/json: fields of embedded structs are promoted unless the
+// embedded field is tagged with a name, shallower fields win over deeper
+// ones, and a tagged field wins over an untagged one at the same depth.
+func typeFields(t reflect.Type) *structFields {
+	type queued struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var all []field
+	var class string
+	current := []queued{}
+	next := []queued{{typ: t}}
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		for _, q := range current {
+			if visited[q.typ] {
+				continue
+			}
+			visited[q.typ] = true
+
+			for i := 0; i < q.typ.NumField(); i++ {
+				sf := q.typ.Field(i)
+				ft := sf.Type
+				if sf.Name == "_" {
+					// A blank marker field names the PHP class of the struct
+					// itself: _ struct{} `igbinary:",class=App\\Dto\\User"`.
+					if len(q.index) == 0 {
+						_, opts := parseTag(sf.Tag.Get("igbinary"))
+						class = opts.value("class")
+					}
+					continue
+				}
+				if sf.Anonymous {
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag := sf.Tag.Get("igbinary")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+
+				index := make([]int, len(q.index)+1)
+				copy(index, q.index)
+				index[len(q.index)] = i
+
+				// Promote the fields of untagged embedded structs.
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					next = append(next, queued{typ: ft, index: index})
+					continue
+				}
+
+				f := field{
+					name:      name,
+					index:     index,
+					typ:       sf.Type,
+					tagged:    name != "",
+					omitEmpty: opts.contains("omitempty"),
+					class:     opts.value("class"),
+				}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				all = append(all, f)
+			}
+		}
+	}
+
+	// Resolve name conflicts: the shallowest field wins, then a tagged field
+	// over an untagged one. Remaining ties drop the name entirely.
+	byName := map[string][]int{}
+	for i, f := range all {
+		byName[f.name] = append(byName[f.name], i)
+	}
+	sf := &structFields{class: class, byName: map[string]int{}, byFold: map[string]int{}}
+	for i, f := range all {
+		if dominant(all, byName[f.name]) != i {
+			continue
+		}
+		sf.list = append(sf.list, f)
+	}
+	for i, f := range sf.list {
+		sf.byName[f.name] = i
+		if _, ok := sf.byFold[strings.ToLower(f.name)]; !ok {
+			sf.byFold[strings.ToLower(f.name)] = i
+		}
+	}
+	return sf
+}
+
+// dominant returns the index of the field that wins among candidates sharing
+// a name, or -1 if there is no single winner.
+func dominant(all []field, candidates []int) int {
+	best := -1
+	tie := false
+	for _, i := range candidates {
+		switch {
+		case best == -1:
+			best = i
+		case len(all[i].index) < len(all[best].index),
+			len(all[i].index) == len(all[best].index) && all[i].tagged && !all[best].tagged:
+			best, tie = i, false
+		case len(all[i].index) == len(all[best].index) && all[i].tagged == all[best].tagged:
+			tie = true
+		}
+	}
+	if tie {
+		return -1
+	}
+	return best
+}
+
+// tagOptions is the comma-separated option list following the name in an
+// igbinary struct tag.
+type tagOptions string
+
+// parseTag splits an igbinary struct tag into its name and options.
+func parseTag(tag string) (string, tagOptions) {
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, tagOptions(opts)
+}
+
+// contains reports whether the option list includes the flag opt.
+func (o tagOptions) contains(opt string) bool {
+	s := string(o)
+	for s != "" {
+		var cur string
+		cur, s, _ = strings.Cut(s, ",")
+		if cur == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the value of a key=value option, or "" if it is absent.
+func (o tagOptions) value(key string) string {
+	s := string(o)
+	for s != "" {
+		var cur string
+		cur, s, _ = strings.Cut(s, ",")
+		if v, ok := strings.CutPrefix(cur, key+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// fieldByIndex returns the struct field at index, allocating nil embedded
+// pointers along the way so the field can be set. A nil pointer to an
+// unexported embedded struct cannot be allocated through reflection.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("%w %s", ErrUnexportedEmbeddedPointer, v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
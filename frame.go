@@ -0,0 +1,68 @@
+package igbinary
+
+import "fmt"
+
+// WithoutHeader makes the decoder read payloads whose 4-byte igbinary
+// header has been stripped, as some storage layers and wire protocols do to
+// save space. The data then starts directly with the root value's type code.
+func WithoutHeader() Option {
+	return func(d *Decoder) {
+		d.noHeader = true
+	}
+}
+
+// WithRejectTrailingData makes [Decoder.Decode], [Decoder.DecodeInto],
+// [Decoder.DecodeValue] and [Decoder.DecodeLazy] fail with
+// [ErrTrailingData] when data continues after the root value. By default
+// such bytes are ignored, which can hide truncated writes being patched
+// over or payloads concatenated by mistake.
+func WithRejectTrailingData() Option {
+	return func(d *Decoder) {
+		d.noTrailing = true
+	}
+}
+
+// DecodeAt decodes the igbinary payload starting at offset in data using
+// default options. See [Decoder.DecodeAt].
+func DecodeAt(data []byte, offset int) (v any, n int, err error) {
+	return defaultDecoder.DecodeAt(data, offset)
+}
+
+// DecodeAt decodes the igbinary payload starting at offset in data, header
+// included, and returns the value and the number of bytes it took. This
+// reads igbinary embedded in a larger frame, or several payloads stored
+// back to back:
+//
+//	for off := 0; off < len(data); {
+//	    v, n, err := dec.DecodeAt(data, off)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    handle(v)
+//	    off += n
+//	}
+//
+// Bytes after the value are never an error for DecodeAt. Error positions are
+// offsets into data, not into the payload.
+func (d *Decoder) DecodeAt(data []byte, offset int) (v any, n int, err error) {
+	r, err := d.newReaderAt(data, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	v, err = r.decodeValue()
+	if err != nil {
+		return nil, 0, err
+	}
+	if d.normalize {
+		v = NormalizeArrays(v, d.normalizeOpts...)
+	}
+	return v, r.pos - offset, nil
+}
+
+// checkEnd reports data left after the root value.
+func (r *reader) checkEnd() error {
+	if n := len(r.data) - r.pos; n > 0 {
+		return newError(ErrTrailingData, r.pos, fmt.Sprintf("%d bytes after the root value", n))
+	}
+	return nil
+}
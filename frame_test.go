@@ -0,0 +1,95 @@
+package igbinary_test
+
+import (
+	"errors"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+func TestTrailingDataIgnoredByDefault(t *testing.T) {
+	val, err := igbinary.Decode(makePayload(0x06, 0x2A, 0xFF))
+	assertNoError(t, err)
+	assertEqualInt64(t, val, 42)
+}
+
+func TestRejectTrailingData(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithRejectTrailingData())
+	_, err := dec.Decode(makePayload(0x06, 0x2A))
+	assertNoError(t, err)
+
+	data := makePayload(0x06, 0x2A, 0xFF, 0xFF)
+	_, err = dec.Decode(data)
+	assertDecodeError(t, err, igbinary.ErrTrailingData)
+	var de *igbinary.DecodeError
+	if errors.As(err, &de) && de.Pos != 6 {
+		t.Errorf("expected pos 6, got %d", de.Pos)
+	}
+
+	_, err = dec.DecodeValue(data)
+	assertDecodeError(t, err, igbinary.ErrTrailingData)
+	_, err = dec.DecodeLazy(data)
+	assertDecodeError(t, err, igbinary.ErrTrailingData)
+	var n int64
+	assertDecodeError(t, dec.DecodeInto(data, &n), igbinary.ErrTrailingData)
+}
+
+func TestDecodeAt(t *testing.T) {
+	// A frame holding a 3-byte prefix, int(42), then ["a" => true].
+	frame := []byte{'h', 'd', 'r'}
+	frame = append(frame, makePayload(0x06, 0x2A)...)
+	frame = append(frame, makePayload(0x14, 0x01, 0x11, 0x01, 'a', 0x05)...)
+
+	val, n, err := igbinary.DecodeAt(frame, 3)
+	assertNoError(t, err)
+	assertEqualInt64(t, val, 42)
+	if n != 6 {
+		t.Fatalf("expected 6 bytes, got %d", n)
+	}
+
+	val, n, err = igbinary.DecodeAt(frame, 3+n)
+	assertNoError(t, err)
+	assertEqualBool(t, val.(map[string]any)["a"], true)
+	if 9+n != len(frame) {
+		t.Errorf("expected to end at %d, got %d", len(frame), 9+n)
+	}
+}
+
+func TestDecodeAtErrorPosition(t *testing.T) {
+	frame := append([]byte{'x', 'x'}, makePayload(0x11, 0x05, 'a')...)
+	_, _, err := igbinary.DecodeAt(frame, 2)
+	var de *igbinary.DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, igbinary.ErrUnexpectedEnd) {
+		t.Fatalf("expected unexpected end, got %v", err)
+	}
+	if de.Pos != 8 {
+		t.Errorf("expected pos 8, got %d", de.Pos)
+	}
+
+	_, _, err = igbinary.DecodeAt(frame, 20)
+	assertDecodeError(t, err, igbinary.ErrDataTooShort)
+}
+
+func TestWithoutHeader(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithoutHeader())
+	val, err := dec.Decode([]byte{0x06, 0x2A})
+	assertNoError(t, err)
+	assertEqualInt64(t, val, 42)
+
+	val, n, err := dec.DecodeAt([]byte{0x00, 0x11, 0x01, 'a', 0x00}, 1)
+	assertNoError(t, err)
+	assertEqualString(t, val, "a")
+	if n != 3 {
+		t.Errorf("expected 3 bytes, got %d", n)
+	}
+
+	_, err = dec.Decode(nil)
+	assertDecodeError(t, err, igbinary.ErrDataTooShort)
+}
+
+func TestWithoutHeaderLazy(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithoutHeader())
+	v, err := dec.DecodeLazy([]byte{0x14, 0x01, 0x11, 0x01, 'a', 0x06, 0x07})
+	assertNoError(t, err)
+	assertEqualInt64(t, v.Get("a").Int(), 7)
+}
@@ -0,0 +1,87 @@
+package igbinary
+
+import "sync"
+
+// DefaultInternMaxLength is the longest string an [InternPool] created with
+// a non-positive maxLength keeps.
+const DefaultInternMaxLength = 64
+
+// InternPool shares string allocations between decodes. Payloads produced by
+// the same PHP code repeat the same array keys, property names and class
+// names over and over; with a pool, each of them is allocated once and every
+// later decode returns the pooled copy:
+//
+//	pool := igbinary.NewInternPool(10000, 0)
+//	dec := igbinary.NewDecoder(igbinary.WithInternPool(pool))
+//
+// A pool is safe for concurrent use and may be shared by several decoders.
+// It is bounded: it keeps at most maxEntries strings of at most maxLength
+// bytes each, and once full it stops adding strings rather than evicting
+// them, so the strings seen first stay pooled. Other strings are allocated
+// as usual.
+type InternPool struct {
+	mu         sync.RWMutex
+	strings    map[string]string
+	maxEntries int
+	maxLength  int
+}
+
+// NewInternPool creates a pool holding at most maxEntries strings of at most
+// maxLength bytes. A non-positive maxLength selects [DefaultInternMaxLength].
+func NewInternPool(maxEntries, maxLength int) *InternPool {
+	if maxLength <= 0 {
+		maxLength = DefaultInternMaxLength
+	}
+	return &InternPool{
+		strings:    make(map[string]string),
+		maxEntries: maxEntries,
+		maxLength:  maxLength,
+	}
+}
+
+// WithInternPool makes the decoder take strings from pool. Array keys,
+// property names, class names and short string values that are already in
+// the pool are returned without allocating; new ones are added while the
+// pool has room.
+//
+// Pooled strings never share memory with the input, so they stay valid
+// under [WithZeroCopyStrings].
+func WithInternPool(pool *InternPool) Option {
+	return func(d *Decoder) {
+		d.intern = pool
+	}
+}
+
+// Len returns the number of strings in the pool.
+func (p *InternPool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.strings)
+}
+
+// lookup returns the pooled string equal to b, adding a copy of b if the
+// pool has room. ok is false if b is not pooled.
+func (p *InternPool) lookup(b []byte) (s string, ok bool) {
+	if len(b) > p.maxLength {
+		return "", false
+	}
+	p.mu.RLock()
+	s, ok = p.strings[string(b)]
+	full := len(p.strings) >= p.maxEntries
+	p.mu.RUnlock()
+	if ok || full {
+		return s, ok
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok = p.strings[string(b)]; ok {
+		return s, true
+	}
+	if len(p.strings) >= p.maxEntries {
+		return "", false
+	}
+	s = string(b)
+	p.strings[s] = s
+	return s, true
+}
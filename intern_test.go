@@ -0,0 +1,84 @@
+package igbinary_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+func TestInternPoolSharesKeys(t *testing.T) {
+	pool := igbinary.NewInternPool(100, 0)
+	dec := igbinary.NewDecoder(igbinary.WithInternPool(pool))
+	data := makePayload(0x14, 0x01, 0x11, 0x02, 'i', 'd', 0x06, 0x01)
+
+	keyData := func() *byte {
+		val, err := dec.Decode(data)
+		assertNoError(t, err)
+		for k := range val.(map[string]any) {
+			return unsafe.StringData(k)
+		}
+		t.Fatal("expected one key")
+		return nil
+	}
+	if first, second := keyData(), keyData(); first != second {
+		t.Error("expected both decodes to share the pooled key")
+	}
+	if pool.Len() != 1 {
+		t.Errorf("expected 1 pooled string, got %d", pool.Len())
+	}
+}
+
+func TestInternPoolBounded(t *testing.T) {
+	pool := igbinary.NewInternPool(1, 4)
+	dec := igbinary.NewDecoder(igbinary.WithInternPool(pool))
+	for _, s := range []string{"a", "b", "longer"} {
+		val, err := dec.Decode(makePayload(append([]byte{0x11, byte(len(s))}, s...)...))
+		assertNoError(t, err)
+		assertEqualString(t, val, s)
+	}
+	if pool.Len() != 1 {
+		t.Errorf("expected 1 pooled string, got %d", pool.Len())
+	}
+}
+
+func TestInternPoolZeroCopy(t *testing.T) {
+	pool := igbinary.NewInternPool(10, 0)
+	dec := igbinary.NewDecoder(igbinary.WithInternPool(pool), igbinary.WithZeroCopyStrings())
+	data := makePayload(0x11, 0x02, 'i', 'd')
+	val, err := dec.Decode(data)
+	assertNoError(t, err)
+	copy(data[6:], "xx")
+	assertEqualString(t, val, "id")
+}
+
+func TestInternPoolConcurrent(t *testing.T) {
+	pool := igbinary.NewInternPool(1000, 0)
+	dec := igbinary.NewDecoder(igbinary.WithInternPool(pool))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := strings.Repeat("k", i%20+1)
+				data := makePayload(append(append([]byte{0x14, 0x01, 0x11, byte(len(key))}, key...), 0x00)...)
+				val, err := dec.Decode(data)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, ok := val.(map[string]any)[key]; !ok {
+					t.Errorf("missing key %q", key)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if pool.Len() != 20 {
+		t.Errorf("expected 20 pooled strings, got %d", pool.Len())
+	}
+}
@@ -0,0 +1,75 @@
+package igbinary
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Key is a PHP array key that remembers whether PHP stored it as an integer
+// or as a string, so that [5 => 'x'] and ['5' => 'x'] stay distinguishable.
+//
+// Decoders created with [WithKeyKinds] return PHP arrays and objects as
+// map[Key]any. Key is comparable and implements [encoding.TextMarshaler], so
+// such maps can be used as map keys and marshaled to JSON directly.
+type Key struct {
+	Int   int64  // the key when IsInt is set
+	Str   string // the key when IsInt is not set
+	IsInt bool
+}
+
+var keyType = reflect.TypeFor[Key]()
+
+// IntKey returns the integer key i.
+func IntKey(i int64) Key {
+	return Key{Int: i, IsInt: true}
+}
+
+// StringKey returns the string key s.
+func StringKey(s string) Key {
+	return Key{Str: s}
+}
+
+// String returns the key as PHP would print it: integer keys in decimal,
+// string keys unchanged.
+func (k Key) String() string {
+	if k.IsInt {
+		return strconv.FormatInt(k.Int, 10)
+	}
+	return k.Str
+}
+
+// MarshalText implements [encoding.TextMarshaler] using [Key.String].
+func (k Key) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// WithKeyKinds makes the decoder keep the kind of every array key. PHP arrays
+// and objects decode as map[Key]any instead of map[string]any, with the class
+// name of an object stored under StringKey([ClassKey]). Ordered maps always
+// record the kind in [Entry.IsInt], so with [WithOrderedMaps] this option has
+// no further effect.
+//
+// [NormalizeArrays] then only turns maps with integer keys 0..N-1 into
+// slices, and [Encode] writes every key back with its original kind.
+func WithKeyKinds() Option {
+	return func(d *Decoder) {
+		d.keyKinds = true
+	}
+}
+
+// sortKeyKinds orders keys the way the encoder writes them: integer keys
+// first in ascending order, then string keys in byte order.
+func sortKeyKinds(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch {
+		case a.IsInt && b.IsInt:
+			return a.Int < b.Int
+		case a.IsInt != b.IsInt:
+			return a.IsInt
+		default:
+			return a.Str < b.Str
+		}
+	})
+}
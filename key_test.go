@@ -0,0 +1,104 @@
+package igbinary_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// mixedKeys is [5 => "int", "5" => "str"]: PHP never produces it itself, but
+// the two keys must stay apart.
+var mixedKeys = makePayload(
+	0x14, 0x02,
+	0x06, 0x05, 0x11, 0x03, 'i', 'n', 't',
+	0x11, 0x01, '5', 0x11, 0x03, 's', 't', 'r',
+)
+
+func decodeKeyKinds(t *testing.T, data []byte) map[igbinary.Key]any {
+	t.Helper()
+	val, err := igbinary.NewDecoder(igbinary.WithKeyKinds()).Decode(data)
+	assertNoError(t, err)
+	m, ok := val.(map[igbinary.Key]any)
+	if !ok {
+		t.Fatalf("expected map[Key]any, got %T", val)
+	}
+	return m
+}
+
+func TestKeyKindsDistinguishIntAndStringKeys(t *testing.T) {
+	m := decodeKeyKinds(t, mixedKeys)
+	assertEqualString(t, m[igbinary.IntKey(5)], "int")
+	assertEqualString(t, m[igbinary.StringKey("5")], "str")
+}
+
+func TestKeyKindsObjectClass(t *testing.T) {
+	data := makePayload(0x17, 0x03, 'F', 'o', 'o', 0x14, 0x01, 0x06, 0x00, 0x06, 0x07)
+	m := decodeKeyKinds(t, data)
+	assertEqualString(t, m[igbinary.StringKey(igbinary.ClassKey)], "Foo")
+	assertEqualInt64(t, m[igbinary.IntKey(0)], 7)
+}
+
+func TestKeyKindsNegativeKey(t *testing.T) {
+	data := makePayload(0x14, 0x01, 0x07, 0x03, 0x00)
+	m := decodeKeyKinds(t, data)
+	if v, ok := m[igbinary.IntKey(-3)]; !ok || v != nil {
+		t.Errorf("expected key -3 => nil, got %#v", m)
+	}
+}
+
+func TestKeyKindsRoundTrip(t *testing.T) {
+	data, err := igbinary.Encode(decodeKeyKinds(t, mixedKeys))
+	assertNoError(t, err)
+	if !reflect.DeepEqual(data, mixedKeys) {
+		t.Errorf("round trip mismatch:\n got %x\nwant %x", data, mixedKeys)
+	}
+}
+
+func TestNormalizeArraysKeyKinds(t *testing.T) {
+	list := map[igbinary.Key]any{igbinary.IntKey(0): "a", igbinary.IntKey(1): "b"}
+	if got := igbinary.NormalizeArrays(list); !reflect.DeepEqual(got, []any{"a", "b"}) {
+		t.Errorf("expected list, got %#v", got)
+	}
+
+	strKeys := map[igbinary.Key]any{igbinary.StringKey("0"): "a", igbinary.StringKey("1"): "b"}
+	if _, ok := igbinary.NormalizeArrays(strKeys).(map[igbinary.Key]any); !ok {
+		t.Error("expected string keys to stay a map")
+	}
+}
+
+func TestKeyMarshalJSON(t *testing.T) {
+	out, err := json.Marshal(map[igbinary.Key]any{igbinary.IntKey(-1): "a", igbinary.StringKey("b"): 2})
+	assertNoError(t, err)
+	if string(out) != `{"-1":"a","b":2}` {
+		t.Errorf("unexpected JSON: %s", out)
+	}
+}
+
+func TestOrderedMapsRecordKeyKinds(t *testing.T) {
+	m := decodeOrdered(t, makePayload(0x14, 0x02, 0x06, 0x05, 0x00, 0x11, 0x01, 'x', 0x00))
+	if e := m.At(0); !e.IsInt || e.PHPKey() != igbinary.IntKey(5) {
+		t.Errorf("expected integer key 5, got %+v", e)
+	}
+	if e := m.At(1); e.IsInt || e.PHPKey() != igbinary.StringKey("x") {
+		t.Errorf("expected string key x, got %+v", e)
+	}
+}
+
+func TestUnmarshalIntoKeyMap(t *testing.T) {
+	var out map[igbinary.Key]string
+	assertNoError(t, igbinary.Unmarshal(mixedKeys, &out))
+	// Without WithKeyKinds the string key "5" is indistinguishable from the
+	// integer key and one entry wins.
+	if len(out) != 1 {
+		t.Errorf("expected 1 entry, got %#v", out)
+	}
+
+	out = nil
+	dec := igbinary.NewDecoder(igbinary.WithKeyKinds())
+	assertNoError(t, dec.DecodeInto(mixedKeys, &out))
+	if out[igbinary.IntKey(5)] != "int" || out[igbinary.StringKey("5")] != "str" {
+		t.Errorf("unexpected result: %#v", out)
+	}
+}
@@ -0,0 +1,128 @@
+package igbinary
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// LargeString stands in a decoded tree for a string value that was handed
+// to the handler of [WithLargeStrings] or written to the sink of
+// [WithLargeStringSink] instead of being decoded. It does not hold the
+// string, so [Encode] returns [ErrUnsupportedValue] for it.
+type LargeString struct {
+	Path   Path  // location of the string in the payload
+	Length int   // length in bytes
+	Offset int64 // offset in the sink, or -1 for a handler
+}
+
+// LargeStringHandler receives a large string value found at path. r yields
+// the string's bytes and is only valid during the call. An error aborts
+// the decode.
+type LargeStringHandler func(path Path, r io.Reader) error
+
+// largeStrings configures where large string values go.
+type largeStrings struct {
+	threshold int
+	handler   LargeStringHandler
+	sink      io.Writer
+}
+
+// WithLargeStrings makes the decoder pass string values of threshold bytes
+// or more to handler instead of decoding them, and put a *[LargeString] in
+// their place:
+//
+//	dec := igbinary.NewDecoder(igbinary.WithLargeStrings(1<<20,
+//	    func(path igbinary.Path, r io.Reader) error {
+//	        return store.Put(path.String(), r)
+//	    }))
+//
+// Array keys, property names and class names are always decoded. A string
+// referenced again later in the payload is handed over only once; every
+// occurrence gets the same placeholder. The option applies to
+// [Decoder.Decode], [Decoder.DecodeInto], [Decoder.DecodeValue],
+// [Decoder.DecodeAt] and [StreamDecoder], not to [Decoder.Get] or
+// [Decoder.DecodeLazy]. A nil handler disables the option.
+func WithLargeStrings(threshold int, handler LargeStringHandler) Option {
+	return func(d *Decoder) {
+		if handler == nil {
+			d.large = largeStrings{}
+			return
+		}
+		d.large = largeStrings{threshold: threshold, handler: handler}
+	}
+}
+
+// WithLargeStringSink is like [WithLargeStrings] but writes large string
+// values to w, one after the other. LargeString.Offset is the position of
+// the string among the bytes written by the same decode, so w should not be
+// shared by concurrent decodes. A nil w disables the option.
+func WithLargeStringSink(threshold int, w io.Writer) Option {
+	return func(d *Decoder) {
+		if w == nil {
+			d.large = largeStrings{}
+			return
+		}
+		d.large = largeStrings{threshold: threshold, sink: w}
+	}
+}
+
+// decodeLargeString decodes the string value that follows a TypeString8,
+// TypeString16 or TypeString32 code if it is large. ok is false, with the
+// reader unchanged, for smaller strings.
+func (r *reader) decodeLargeString(code byte) (v any, ok bool, err error) {
+	start := r.pos
+	length, err := r.readSized(code, TypeString8)
+	if err != nil {
+		return nil, false, err
+	}
+	if length < r.large.threshold {
+		r.pos = start
+		return nil, false, nil
+	}
+	if err := r.checkString(length); err != nil {
+		return nil, false, err
+	}
+	b, err := r.readBytes(length)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The string table keeps a copy-free view of the string in case a key
+	// or class name refers to it; lookupString copies it then.
+	if r.nstrings == len(r.strings) {
+		r.strings = append(r.strings, unsafe.String(unsafe.SliceData(b), len(b)))
+		if r.byteStrings {
+			r.stringBytes = append(r.stringBytes, b[:len(b):len(b)])
+		}
+	}
+	r.nstrings++
+
+	ls := &LargeString{Path: r.currentPath(), Length: length, Offset: -1}
+	if r.large.sink != nil {
+		ls.Offset = r.sinkWritten
+		n, err := r.large.sink.Write(b)
+		r.sinkWritten += int64(n)
+		if err != nil {
+			return nil, false, fmt.Errorf("igbinary: large string sink at %s: %w", ls.Path, err)
+		}
+	} else if err := r.large.handler(ls.Path, bytes.NewReader(b)); err != nil {
+		return nil, false, fmt.Errorf("igbinary: large string handler at %s: %w", ls.Path, err)
+	}
+
+	if r.largeByID == nil {
+		r.largeByID = make(map[int]*LargeString)
+	}
+	r.largeByID[r.nstrings-1] = ls
+	return ls, true, nil
+}
+
+// currentPath returns the path of the value being decoded.
+func (r *reader) currentPath() Path {
+	p := Path{segments: make([]pathSegment, len(r.path))}
+	for i, k := range r.path {
+		p.segments[i] = pathSegment{str: k.String(), int: k.Int, isInt: k.IsInt}
+	}
+	return p
+}
@@ -0,0 +1,116 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// largePayload is ["html" => <blob>, "items" => [0 => <blob> (string ID 1)],
+// <blob> (as key by string ID 1) => 1] where <blob> is 300 bytes.
+func largePayload() []byte {
+	blob := strings.Repeat("x", 300)
+	body := []byte{0x14, 0x03, 0x11, 0x04, 'h', 't', 'm', 'l', 0x12, 0x01, 0x2C}
+	body = append(body, blob...)
+	body = append(body, 0x11, 0x05, 'i', 't', 'e', 'm', 's', 0x14, 0x01, 0x06, 0x00, 0x0E, 0x01)
+	body = append(body, 0x0E, 0x01, 0x06, 0x01)
+	return makePayload(body...)
+}
+
+func TestLargeStringsHandler(t *testing.T) {
+	var paths []string
+	var got []byte
+	dec := igbinary.NewDecoder(igbinary.WithLargeStrings(256, func(path igbinary.Path, r io.Reader) error {
+		paths = append(paths, path.String())
+		b, err := io.ReadAll(r)
+		got = b
+		return err
+	}))
+	val, err := dec.Decode(largePayload())
+	assertNoError(t, err)
+
+	if len(paths) != 1 || paths[0] != "$.html" {
+		t.Fatalf("expected one call for $.html, got %v", paths)
+	}
+	if string(got) != strings.Repeat("x", 300) {
+		t.Errorf("handler got %d bytes", len(got))
+	}
+
+	m := val.(map[string]any)
+	ls, ok := m["html"].(*igbinary.LargeString)
+	if !ok {
+		t.Fatalf("expected *LargeString, got %T", m["html"])
+	}
+	if ls.Length != 300 || ls.Offset != -1 || ls.Path.String() != "$.html" {
+		t.Errorf("unexpected placeholder %+v", ls)
+	}
+	if m["items"].(map[string]any)["0"] != ls {
+		t.Error("expected the back-reference to share the placeholder")
+	}
+	assertEqualInt64(t, m[strings.Repeat("x", 300)], 1)
+}
+
+func TestLargeStringsBelowThreshold(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithLargeStrings(301, func(igbinary.Path, io.Reader) error {
+		t.Error("handler called for a small string")
+		return nil
+	}))
+	val, err := dec.Decode(largePayload())
+	assertNoError(t, err)
+	assertEqualString(t, val.(map[string]any)["html"], strings.Repeat("x", 300))
+}
+
+func TestLargeStringsNilHandler(t *testing.T) {
+	for _, opt := range []igbinary.Option{
+		igbinary.WithLargeStrings(1, nil),
+		igbinary.WithLargeStringSink(1, nil),
+	} {
+		val, err := igbinary.NewDecoder(opt).Decode(largePayload())
+		assertNoError(t, err)
+		assertEqualString(t, val.(map[string]any)["html"], strings.Repeat("x", 300))
+	}
+}
+
+func TestLargeStringsEncode(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithLargeStrings(256, func(igbinary.Path, io.Reader) error {
+		return nil
+	}))
+	val, err := dec.Decode(largePayload())
+	assertNoError(t, err)
+	if _, err := igbinary.Encode(val); !errors.Is(err, igbinary.ErrUnsupportedValue) {
+		t.Errorf("expected ErrUnsupportedValue for the placeholder, got %v", err)
+	}
+}
+
+func TestLargeStringsHandlerError(t *testing.T) {
+	boom := errors.New("boom")
+	dec := igbinary.NewDecoder(igbinary.WithLargeStrings(1, func(igbinary.Path, io.Reader) error {
+		return boom
+	}))
+	_, err := dec.Decode(makePayload(0x11, 0x01, 'a'))
+	if !errors.Is(err, boom) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+}
+
+func TestLargeStringSink(t *testing.T) {
+	var sink bytes.Buffer
+	data := makePayload(0x14, 0x02,
+		0x06, 0x00, 0x11, 0x03, 'a', 'b', 'c',
+		0x06, 0x01, 0x11, 0x04, 'd', 'e', 'f', 'g',
+	)
+	val, err := igbinary.NewDecoder(igbinary.WithLargeStringSink(3, &sink)).Decode(data)
+	assertNoError(t, err)
+	if sink.String() != "abcdefg" {
+		t.Errorf("expected sink to hold abcdefg, got %q", sink.String())
+	}
+	m := val.(map[string]any)
+	second := m["1"].(*igbinary.LargeString)
+	if second.Offset != 3 || second.Length != 4 || second.Path.String() != "$[1]" {
+		t.Errorf("unexpected placeholder %+v", second)
+	}
+}
@@ -0,0 +1,198 @@
+package igbinary
+
+// DecodeLazy decodes igbinary-serialized data into a lazy [Value] tree using
+// default options. See [Decoder.DecodeLazy].
+func DecodeLazy(data []byte) (Value, error) {
+	return defaultDecoder.DecodeLazy(data)
+}
+
+// DecodeLazy decodes igbinary-serialized data into a lazy [Value] tree.
+// Arrays and objects are decoded only when first accessed through
+// [Value.Get], [Value.Index], [Value.Entries] and the other accessors, one
+// level at a time, and cached after that:
+//
+//	v, err := dec.DecodeLazy(data)
+//	email := v.Get("customer").Get("email").Str() // decodes two levels only
+//
+// DecodeLazy reads the whole payload once up front, without building any
+// values, so malformed data is still reported here and accessing the tree
+// cannot fail later. [Value.Interface] and [Value.Decode] decode the rest of
+// the subtree they are called on. The tree follows the same conventions as
+// [Decoder.DecodeValue], and is not safe for concurrent use.
+func (d *Decoder) DecodeLazy(data []byte) (Value, error) {
+	r, err := d.newReader(data)
+	if err != nil {
+		return Value{}, err
+	}
+	r.ordered = true
+	r.refs = true
+	r.tree = true
+	r.classes = nil
+	r.spl = false
+	start := r.pos
+	if err := r.skipValue(); err != nil {
+		return Value{}, err
+	}
+	if d.noTrailing {
+		if err := r.checkEnd(); err != nil {
+			return Value{}, err
+		}
+	}
+
+	doc := &lazyDoc{template: *r, nodes: make([]lazyNode, len(r.values))}
+	doc.template.pos = start
+	doc.template.nstrings = 0
+	// The limits were checked for the whole payload already.
+	doc.template.elements = 0
+	doc.template.allocated = 0
+	for i := range doc.nodes {
+		doc.nodes[i] = lazyNode{doc: doc, at: r.values[i].(*skippedValue)}
+	}
+	if len(doc.nodes) > 0 {
+		switch root := &doc.nodes[0]; {
+		case root.at.pos == start:
+			return Value{v: root, tree: true}, nil
+		case root.at.simple && root.at.pos == start+1:
+			// A PHP reference to an array or object.
+			return Value{v: root.reference(), tree: true}, nil
+		}
+	}
+
+	// The root is a scalar.
+	root := doc.template
+	val, err := root.decodeValue()
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{v: val, tree: true}, nil
+}
+
+// lazyDoc is a payload read by DecodeLazy.
+type lazyDoc struct {
+	// template is a reader holding the complete string table and a values
+	// table of skippedValue entries, one per array and object.
+	template reader
+	nodes    []lazyNode // by values table ID
+}
+
+// lazyNode is an array or object of a lazy tree.
+type lazyNode struct {
+	doc     *lazyDoc
+	at      *skippedValue
+	shallow any // the container with nested arrays and objects still lazy
+	full    any // the fully decoded value
+	ref     *Reference
+}
+
+// readerAt returns a reader positioned at s, with the string table as it
+// was at that point.
+func (d *lazyDoc) readerAt(s *skippedValue) *reader {
+	r := d.template
+	r.pos = s.pos
+	r.nstrings = s.nstrings
+	r.values = r.values[:s.id:s.id]
+	return &r
+}
+
+// load decodes the node one level deep: scalar entries are decoded, nested
+// arrays and objects become lazy nodes and back-references point to them.
+func (n *lazyNode) load() any {
+	if n.shallow == nil {
+		n.shallow = n.doc.loadShallow(n.at)
+	}
+	return n.shallow
+}
+
+func (d *lazyDoc) loadShallow(s *skippedValue) any {
+	r := d.readerAt(s)
+	code, _ := r.readByte()
+
+	var node any
+	var count int
+	switch code {
+	case TypeArray8, TypeArray16, TypeArray32:
+		count, _ = r.readSized(code, TypeArray8)
+		node = NewOrderedMap(count)
+	case TypeObject8, TypeObject16, TypeObject32, TypeObjectID8, TypeObjectID16, TypeObjectID32:
+		class, _ := r.readClassName(code)
+		count, _ = r.readPropertyCount()
+		node = r.newObject(class, count)
+	default:
+		// A serialized object has no nested values.
+		r.pos = s.pos
+		v, _ := r.decodeValue()
+		return v
+	}
+
+	next := s.id + 1
+	for i := 0; i < count; i++ {
+		key, _ := r.decodeArrayKey()
+		setEntry(node, key, d.loadEntry(r, &next))
+	}
+	return node
+}
+
+// loadEntry reads one entry value for loadShallow. next is the values
+// table ID the next nested array or object will have.
+func (d *lazyDoc) loadEntry(r *reader, next *int) any {
+	code := r.data[r.pos]
+	switch {
+	case isCompoundCode(code):
+		return d.skipNode(r, next)
+
+	case backRefBase(code) != 0:
+		r.pos++
+		base := backRefBase(code)
+		id, _ := r.readSized(code, base)
+		target := &d.nodes[id]
+		if target.at.simple {
+			return target.reference()
+		}
+		return &Reference{Object: base == TypeObjectRef8, Target: target}
+
+	case code == TypeSimpleRef:
+		inner := r.data[r.pos+1]
+		if base := backRefBase(inner); base != 0 {
+			r.pos += 2
+			id, _ := r.readSized(inner, base)
+			return d.nodes[id].reference()
+		}
+		if isCompoundCode(inner) || inner == TypeSimpleRef {
+			r.pos++
+		}
+		// A referenced scalar has a node starting at the TypeSimpleRef code.
+		return d.skipNode(r, next).reference()
+	}
+	v, _ := r.decodeValue()
+	return v
+}
+
+// skipNode returns the node of the value at the reader's position and moves
+// the reader past it. next is the values table ID of that node.
+func (d *lazyDoc) skipNode(r *reader, next *int) *lazyNode {
+	child := &d.nodes[*next]
+	r.pos, r.nstrings, *next = child.at.end, child.at.nstringsEnd, child.at.idEnd
+	return child
+}
+
+// reference returns the *Reference shared by every slot holding the PHP
+// reference (&$var) at n.
+func (n *lazyNode) reference() *Reference {
+	if n.ref == nil {
+		if n.doc.template.data[n.at.pos] == TypeSimpleRef {
+			// A referenced scalar decodes to its *Reference.
+			n.ref = n.load().(*Reference)
+		} else {
+			n.ref = &Reference{Simple: true, Target: n}
+		}
+	}
+	return n.ref
+}
+
+// materialize decodes the whole subtree of the node.
+func (n *lazyNode) materialize() any {
+	if n.full == nil {
+		n.full, _ = n.doc.readerAt(n.at).decodeValue()
+	}
+	return n.full
+}
@@ -0,0 +1,110 @@
+package igbinary_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+func TestDecodeLazyAccessors(t *testing.T) {
+	data := pathPayload(t)
+	v, err := igbinary.DecodeLazy(data)
+	assertNoError(t, err)
+
+	if v.Kind() != igbinary.Array || v.Len() != 3 {
+		t.Fatalf("expected array of 3, got %s of %d", v.Kind(), v.Len())
+	}
+	user := v.Get("user")
+	if user.Get("id").Int() != 42 || user.Get("role").Str() != "shared" {
+		t.Errorf("unexpected user: id=%d role=%q", user.Get("id").Int(), user.Get("role").Str())
+	}
+	if got := user.Get("tags").Index(1).Str(); got != "y" {
+		t.Errorf("expected tags[1] = y, got %q", got)
+	}
+
+	buyer := user.Get("buyer")
+	if buyer.Kind() != igbinary.Ref || buyer.Class() != `App\Customer` || buyer.Get("name").Str() != "Ann" {
+		t.Errorf("unexpected buyer: %s %q", buyer.Kind(), buyer.Class())
+	}
+	if got := v.Get("b_owner").Class(); got != `App\Customer` {
+		t.Errorf("unexpected owner class %q", got)
+	}
+}
+
+func TestDecodeLazyMatchesDecodeValue(t *testing.T) {
+	data := pathPayload(t)
+	lazy, err := igbinary.DecodeLazy(data)
+	assertNoError(t, err)
+
+	out, err := igbinary.Encode(lazy)
+	assertNoError(t, err)
+	if !reflect.DeepEqual(out, data) {
+		t.Errorf("round trip mismatch:\n got %x\nwant %x", out, data)
+	}
+
+	full, err := igbinary.DecodeValue(data)
+	assertNoError(t, err)
+	a, _ := igbinary.Encode(lazy.Get("user").Get("tags"))
+	b, _ := igbinary.Encode(full.Get("user").Get("tags"))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("subtree mismatch: %x vs %x", a, b)
+	}
+}
+
+func TestDecodeLazyInterfaceAndDecode(t *testing.T) {
+	v, err := igbinary.DecodeLazy(pathPayload(t))
+	assertNoError(t, err)
+
+	tags, ok := v.Get("user").Get("tags").Interface().(*igbinary.OrderedMap)
+	if !ok || tags.Len() != 2 {
+		t.Fatalf("expected *OrderedMap of 2, got %#v", v.Get("user").Get("tags").Interface())
+	}
+
+	var user struct {
+		ID   int      `igbinary:"id"`
+		Tags []string `igbinary:"tags"`
+	}
+	assertNoError(t, v.Get("user").Decode(&user))
+	if user.ID != 42 || len(user.Tags) != 2 {
+		t.Errorf("unexpected user: %+v", user)
+	}
+}
+
+func TestDecodeLazyCycle(t *testing.T) {
+	node := map[string]any{igbinary.ClassKey: "Node", "name": "n"}
+	node["self"] = node
+	data, err := igbinary.Encode(node)
+	assertNoError(t, err)
+
+	v, err := igbinary.DecodeLazy(data)
+	assertNoError(t, err)
+	if got := v.Get("self").Get("self").Get("name").Str(); got != "n" {
+		t.Errorf("expected name through cycle, got %q", got)
+	}
+}
+
+func TestDecodeLazyScalarRoot(t *testing.T) {
+	v, err := igbinary.DecodeLazy(makePayload(0x11, 0x02, 'h', 'i'))
+	assertNoError(t, err)
+	if v.Kind() != igbinary.String || v.Str() != "hi" {
+		t.Errorf("unexpected root: %s %q", v.Kind(), v.Str())
+	}
+}
+
+func TestDecodeLazyReportsErrorsUpFront(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want error
+	}{
+		{makePayload(0x14, 0x02, 0x06, 0x00, 0x00), igbinary.ErrUnexpectedEnd},
+		{makePayload(0x14, 0x01, 0x06, 0x00, 0x0e, 0x03), igbinary.ErrStringIDOutOfRange},
+		{makePayload(0x14, 0x01, 0x06, 0x00, 0x01, 0x04), igbinary.ErrValueRefOutOfRange},
+	}
+	for _, tt := range tests {
+		if _, err := igbinary.DecodeLazy(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%x: expected %v, got %v", tt.data, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package igbinary
+
+import "fmt"
+
+// DefaultMaxDepth is the nesting depth of arrays and objects a [Decoder]
+// accepts unless configured otherwise with [WithMaxDepth].
+const DefaultMaxDepth = 1000
+
+// entryCost approximates the memory a decoded array entry or object
+// property takes, for [WithMaxTotalBytes].
+const entryCost = 64
+
+// limits bounds the resources a single decode may use. Zero means no limit.
+type limits struct {
+	maxDepth        int
+	maxElements     int
+	maxStringLength int
+	maxTotalBytes   int64
+}
+
+// WithMaxDepth limits how deeply arrays and objects may be nested. Deeper
+// payloads fail with [ErrMaxDepthExceeded] instead of growing the stack
+// without bound. The default is [DefaultMaxDepth]; n <= 0 removes the limit.
+func WithMaxDepth(n int) Option {
+	return func(d *Decoder) {
+		d.limits.maxDepth = max(n, 0)
+	}
+}
+
+// WithMaxElements limits the total number of array entries and object
+// properties in a payload, counted as their headers are read, so a forged
+// count fails with [ErrMaxElementsExceeded] before anything is allocated
+// for it. n <= 0 removes the limit, which is the default.
+func WithMaxElements(n int) Option {
+	return func(d *Decoder) {
+		d.limits.maxElements = max(n, 0)
+	}
+}
+
+// WithMaxStringLength limits the length in bytes of each string in a
+// payload, including array keys, class names and the data of serialized
+// objects. Longer strings fail with [ErrMaxStringLengthExceeded]. n <= 0
+// removes the limit, which is the default.
+func WithMaxStringLength(n int) Option {
+	return func(d *Decoder) {
+		d.limits.maxStringLength = max(n, 0)
+	}
+}
+
+// WithMaxTotalBytes limits the approximate memory a decode may allocate for
+// the values it returns: the bytes of every string plus a fixed cost per
+// array entry and object property. Payloads that need more fail with
+// [ErrMaxTotalBytesExceeded]. n <= 0 removes the limit, which is the
+// default.
+func WithMaxTotalBytes(n int64) Option {
+	return func(d *Decoder) {
+		d.limits.maxTotalBytes = max(n, 0)
+	}
+}
+
+// enter starts an array or object of n entries, checking the depth, element
+// and total size limits. Every successful enter is paired with a leave.
+func (r *reader) enter(n int) error {
+	if r.limits.maxDepth > 0 && r.depth >= r.limits.maxDepth {
+		return newError(ErrMaxDepthExceeded, r.pos,
+			fmt.Sprintf("limit %d", r.limits.maxDepth))
+	}
+	r.elements += n
+	if r.limits.maxElements > 0 && r.elements > r.limits.maxElements {
+		return newError(ErrMaxElementsExceeded, r.pos,
+			fmt.Sprintf("%d entries, limit %d", r.elements, r.limits.maxElements))
+	}
+	if err := r.alloc(int64(n) * entryCost); err != nil {
+		return err
+	}
+	r.depth++
+	return nil
+}
+
+// leave ends an array or object started with enter.
+func (r *reader) leave() {
+	r.depth--
+}
+
+// alloc accounts for n bytes allocated by the decode.
+func (r *reader) alloc(n int64) error {
+	r.allocated += n
+	if r.limits.maxTotalBytes > 0 && r.allocated > r.limits.maxTotalBytes {
+		return newError(ErrMaxTotalBytesExceeded, r.pos,
+			fmt.Sprintf("%d bytes, limit %d", r.allocated, r.limits.maxTotalBytes))
+	}
+	return nil
+}
+
+// checkString checks a string length read from the input against the
+// string length limit, before the string is read.
+func (r *reader) checkString(n int) error {
+	if r.limits.maxStringLength > 0 && n > r.limits.maxStringLength {
+		return newError(ErrMaxStringLengthExceeded, r.pos,
+			fmt.Sprintf("%d bytes, limit %d", n, r.limits.maxStringLength))
+	}
+	return nil
+}
+
+// capacity returns the capacity to preallocate for a container declared to
+// hold n entries. Each entry takes at least two bytes of input, so a count
+// beyond that is capped rather than trusted.
+func (r *reader) capacity(n int) int {
+	return min(n, (len(r.data)-r.pos)/2)
+}
@@ -0,0 +1,112 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// nestedArrays returns depth arrays nested under key 0 of each other.
+func nestedArrays(depth int) []byte {
+	body := bytes.Repeat([]byte{0x14, 0x01, 0x06, 0x00}, depth-1)
+	return makePayload(append(body, 0x14, 0x00)...)
+}
+
+func assertDecodeError(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+	var de *igbinary.DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected *DecodeError, got %T", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithMaxDepth(3))
+	_, err := dec.Decode(nestedArrays(3))
+	assertNoError(t, err)
+	_, err = dec.Decode(nestedArrays(4))
+	assertDecodeError(t, err, igbinary.ErrMaxDepthExceeded)
+}
+
+func TestDefaultMaxDepth(t *testing.T) {
+	_, err := igbinary.Decode(nestedArrays(igbinary.DefaultMaxDepth + 1))
+	assertDecodeError(t, err, igbinary.ErrMaxDepthExceeded)
+
+	dec := igbinary.NewDecoder(igbinary.WithMaxDepth(0))
+	_, err = dec.Decode(nestedArrays(igbinary.DefaultMaxDepth + 1))
+	assertNoError(t, err)
+}
+
+func TestMaxDepthLazy(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithMaxDepth(3))
+	_, err := dec.DecodeLazy(nestedArrays(4))
+	assertDecodeError(t, err, igbinary.ErrMaxDepthExceeded)
+}
+
+func TestMaxDepthTokenizer(t *testing.T) {
+	_, err := igbinary.NewTokenizer(nestedArrays(igbinary.DefaultMaxDepth)).Decode()
+	assertNoError(t, err)
+	_, err = igbinary.NewTokenizer(nestedArrays(igbinary.DefaultMaxDepth + 1)).Decode()
+	assertDecodeError(t, err, igbinary.ErrMaxDepthExceeded)
+
+	dec := igbinary.NewDecoder(igbinary.WithMaxDepth(3))
+	_, err = dec.NewTokenizer(nestedArrays(3)).Decode()
+	assertNoError(t, err)
+	_, err = dec.NewReaderTokenizer(bytes.NewReader(nestedArrays(4))).Decode()
+	assertDecodeError(t, err, igbinary.ErrMaxDepthExceeded)
+
+	unlimited := igbinary.NewDecoder(igbinary.WithMaxDepth(0))
+	_, err = unlimited.NewTokenizer(nestedArrays(igbinary.DefaultMaxDepth + 1)).Decode()
+	assertNoError(t, err)
+}
+
+func TestMaxElements(t *testing.T) {
+	// [[1], 2]: three entries in total.
+	data := makePayload(0x14, 0x02, 0x06, 0x00, 0x14, 0x01, 0x06, 0x00, 0x06, 0x01, 0x06, 0x01, 0x06, 0x02)
+	_, err := igbinary.NewDecoder(igbinary.WithMaxElements(3)).Decode(data)
+	assertNoError(t, err)
+	_, err = igbinary.NewDecoder(igbinary.WithMaxElements(2)).Decode(data)
+	assertDecodeError(t, err, igbinary.ErrMaxElementsExceeded)
+}
+
+func TestMaxElementsForgedCount(t *testing.T) {
+	// An array claiming 2^32-1 entries in a 12-byte payload.
+	data := makePayload(0x16, 0xFF, 0xFF, 0xFF, 0xFF, 0x06, 0x00, 0x00)
+	_, err := igbinary.NewDecoder(igbinary.WithMaxElements(1000)).Decode(data)
+	assertDecodeError(t, err, igbinary.ErrMaxElementsExceeded)
+
+	// Without a limit, the count is not trusted for preallocation and the
+	// payload simply runs out.
+	_, err = igbinary.Decode(data)
+	assertDecodeError(t, err, igbinary.ErrUnexpectedEnd)
+}
+
+func TestMaxStringLength(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithMaxStringLength(4))
+	_, err := dec.Decode(makePayload(0x11, 0x04, 'a', 'b', 'c', 'd'))
+	assertNoError(t, err)
+	_, err = dec.Decode(makePayload(0x11, 0x05, 'a', 'b', 'c', 'd', 'e'))
+	assertDecodeError(t, err, igbinary.ErrMaxStringLengthExceeded)
+
+	// Serialized object data counts as well.
+	data := makePayload(0x1D, 0x01, 'C', 0x11, 0x05, 'x', 'x', 'x', 'x', 'x')
+	_, err = dec.Decode(data)
+	assertDecodeError(t, err, igbinary.ErrMaxStringLengthExceeded)
+}
+
+func TestMaxTotalBytes(t *testing.T) {
+	// ["a" => "xxxxxxxxxx", "b" => "xxxxxxxxxx"] (the second value by ID).
+	data := makePayload(0x14, 0x02,
+		0x11, 0x01, 'a', 0x11, 0x0A, 'x', 'x', 'x', 'x', 'x', 'x', 'x', 'x', 'x', 'x',
+		0x11, 0x01, 'b', 0x0E, 0x01,
+	)
+	_, err := igbinary.NewDecoder(igbinary.WithMaxTotalBytes(1024)).Decode(data)
+	assertNoError(t, err)
+	_, err = igbinary.NewDecoder(igbinary.WithMaxTotalBytes(64)).Decode(data)
+	assertDecodeError(t, err, igbinary.ErrMaxTotalBytesExceeded)
+}
@@ -0,0 +1,161 @@
+package igbinary
+
+import (
+	"reflect"
+)
+
+// Marshal encodes v into igbinary format, including the 4-byte header.
+//
+// Marshal is the counterpart of [Unmarshal] and accepts the same Go types as
+// [Encoder.Encode]. Structs are written as PHP arrays keyed by field name, or
+// as PHP objects when a class name is configured through a struct tag:
+//
+//	type User struct {
+//	    _       struct{} `igbinary:",class=App\\Dto\\User"`
+//	    ID      int64    `igbinary:"id"`
+//	    Email   string   `igbinary:"email,omitempty"`
+//	    Token   string   `igbinary:"-"`
+//	    Address Address  `igbinary:"address,class=App\\Dto\\Address"`
+//	}
+//
+// The class option on the blank "_" field names the PHP class of the struct
+// itself; the class option on any other field names the class used for that
+// field's struct value. Field tags follow the same rules as [Decoder.DecodeInto]:
+// "-" skips a field, fields of embedded structs are promoted, and "omitempty"
+// omits false, 0, nil pointers and interfaces, and empty strings, slices and maps.
+//
+// Pointers to class-tagged structs keep their identity: when the same pointer
+// is reached twice, the second occurrence is written as an object
+// back-reference, so PHP sees one shared object just as it would after
+// serializing the same instance twice.
+//
+// This is a convenience wrapper around [Encoder.Encode] using default options.
+func Marshal(v any) ([]byte, error) {
+	return defaultEncoder.Encode(v)
+}
+
+// encodeStructPointer writes the struct that rv points to. When the struct is
+// written as a PHP object, a pointer seen before becomes an object
+// back-reference.
+func (w *writer) encodeStructPointer(rv reflect.Value, class string) error {
+	if class == "" {
+		class = cachedFields(rv.Type().Elem()).class
+	}
+	if class != "" {
+		if id, ok := w.objects[keyOf(rv)]; ok {
+			w.writeSized(TypeObjectRef8, id)
+			return nil
+		}
+	}
+
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.leave()
+
+	return w.encodeStruct(rv.Elem(), class, keyOf(rv))
+}
+
+// encodeStruct writes a struct as a PHP array, or as a PHP object when class
+// is set or the struct type declares one. A non-zero key is the pointer the
+// struct was reached through and is recorded as the object's identity.
+func (w *writer) encodeStruct(rv reflect.Value, class string, key valueKey) error {
+	fields := cachedFields(rv.Type())
+	if class == "" {
+		class = fields.class
+	}
+
+	type entry struct {
+		f *field
+		v reflect.Value
+	}
+	entries := make([]entry, 0, len(fields.list))
+	for i := range fields.list {
+		f := &fields.list[i]
+		fv, ok := fieldByIndexNoAlloc(rv, f.index)
+		if !ok {
+			continue
+		}
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		entries = append(entries, entry{f: f, v: fv})
+	}
+
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.leave()
+
+	if class != "" {
+		if err := w.writeObjectHeader(class, len(entries)); err != nil {
+			return err
+		}
+		if key.ptr != 0 {
+			w.markObject(key)
+		}
+	} else {
+		w.writeArrayHeader(len(entries))
+	}
+
+	for _, e := range entries {
+		if err := w.writeKey(e.f.name); err != nil {
+			return err
+		}
+		if err := w.encodeField(e.v, e.f.class); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeField writes a struct field value, applying the field's class option
+// to struct values and pointers to structs.
+func (w *writer) encodeField(fv reflect.Value, class string) error {
+	if class != "" {
+		switch {
+		case fv.Kind() == reflect.Struct:
+			return w.encodeStruct(fv, class, valueKey{})
+		case fv.Kind() == reflect.Pointer && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct:
+			return w.encodeStructPointer(fv, class)
+		}
+	}
+	if m, ok := addrMarshaler(fv); ok {
+		return w.encodeMarshaler(m)
+	}
+	return w.encodeValue(fv.Interface())
+}
+
+// fieldByIndexNoAlloc returns the struct field at index, reporting false when
+// an embedded pointer along the way is nil.
+func fieldByIndexNoAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty for the purposes of omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+type marshalAddress struct {
+	City string `igbinary:"city"`
+}
+
+type marshalTimestamps struct {
+	Created int64 `igbinary:"created_at"`
+}
+
+type marshalUser struct {
+	_ struct{} `igbinary:",class=App\\Dto\\User"`
+	marshalTimestamps
+	ID      int64          `igbinary:"id"`
+	Email   string         `igbinary:"email,omitempty"`
+	Secret  string         `igbinary:"-"`
+	Address marshalAddress `igbinary:"address,class=App\\Dto\\Address"`
+	Billing marshalAddress `igbinary:"billing"`
+}
+
+func TestMarshalStructAsArray(t *testing.T) {
+	data, err := igbinary.Marshal(marshalAddress{City: "Oslo"})
+	assertNoError(t, err)
+	want := makePayload(
+		0x14, 0x01,
+		0x11, 0x04, 'c', 'i', 't', 'y',
+		0x11, 0x04, 'O', 's', 'l', 'o',
+	)
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestMarshalStructAsObject(t *testing.T) {
+	data, err := igbinary.Marshal(marshalUser{
+		marshalTimestamps: marshalTimestamps{Created: 100},
+		ID:                7,
+		Secret:            "hidden",
+		Address:           marshalAddress{City: "Oslo"},
+		Billing:           marshalAddress{City: "Bergen"},
+	})
+	assertNoError(t, err)
+	if data[4] != igbinary.TypeObject8 {
+		t.Fatalf("expected TypeObject8, got 0x%02x", data[4])
+	}
+
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	assertEqualString(t, m[igbinary.ClassKey], "App\\Dto\\User")
+	assertEqualInt64(t, m["id"], 7)
+	assertEqualInt64(t, m["created_at"], 100)
+	if _, ok := m["email"]; ok {
+		t.Error("expected omitempty to drop email")
+	}
+	if _, ok := m["Secret"]; ok {
+		t.Error("expected - to drop Secret")
+	}
+
+	addr := m["address"].(map[string]any)
+	assertEqualString(t, addr[igbinary.ClassKey], "App\\Dto\\Address")
+	assertEqualString(t, addr["city"], "Oslo")
+
+	billing := m["billing"].(map[string]any)
+	if _, ok := billing[igbinary.ClassKey]; ok {
+		t.Error("expected billing to be a plain array")
+	}
+	assertEqualString(t, billing["city"], "Bergen")
+}
+
+func TestMarshalSharedPointerUsesObjectRef(t *testing.T) {
+	u := &marshalUser{ID: 1}
+	data, err := igbinary.Marshal([]*marshalUser{u, u})
+	assertNoError(t, err)
+
+	// Outer array is value 0, the user object is value 1, its address object
+	// is value 2. The second element must be ObjectRef8(1).
+	if !bytes.HasSuffix(data, []byte{0x06, 0x01, 0x22, 0x01}) {
+		t.Errorf("expected trailing ObjectRef8(1), got % x", data)
+	}
+
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	first := m["0"].(map[string]any)
+	second := m["1"].(map[string]any)
+	if reflect.ValueOf(first).Pointer() != reflect.ValueOf(second).Pointer() {
+		t.Error("expected both elements to decode to the same object")
+	}
+}
+
+type marshalInner struct {
+	_ struct{} `igbinary:",class=Inner"`
+	V int64    `igbinary:"v"`
+}
+
+type marshalOuter struct {
+	In marshalInner `igbinary:"in"`
+	_  struct{}     `igbinary:",class=Outer"`
+}
+
+func TestMarshalPointerToFirstFieldIsNotTheStruct(t *testing.T) {
+	o := &marshalOuter{In: marshalInner{V: 7}}
+	data, err := igbinary.Marshal([]any{o, &o.In})
+	assertNoError(t, err)
+
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	assertEqualString(t, m["0"].(map[string]any)[igbinary.ClassKey], "Outer")
+	second := m["1"].(map[string]any)
+	assertEqualString(t, second[igbinary.ClassKey], "Inner")
+	assertEqualInt64(t, second["v"], 7)
+}
+
+type marshalNode struct {
+	_    struct{}     `igbinary:",class=Node"`
+	Next *marshalNode `igbinary:"next"`
+}
+
+func TestMarshalCyclicObjectPointer(t *testing.T) {
+	n := &marshalNode{}
+	n.Next = n
+	data, err := igbinary.Marshal(n)
+	assertNoError(t, err)
+	want := makePayload(
+		0x17, 0x04, 'N', 'o', 'd', 'e',
+		0x14, 0x01,
+		0x11, 0x04, 'n', 'e', 'x', 't',
+		0x22, 0x00, // ObjectRef8(0)
+	)
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	in := marshalUser{
+		marshalTimestamps: marshalTimestamps{Created: 5},
+		ID:                9,
+		Email:             "a@b.c",
+		Address:           marshalAddress{City: "Lima"},
+	}
+	data, err := igbinary.Marshal(in)
+	assertNoError(t, err)
+
+	var out marshalUser
+	assertNoError(t, igbinary.Unmarshal(data, &out))
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
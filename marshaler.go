@@ -0,0 +1,101 @@
+package igbinary
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Marshaler is implemented by types that write their own igbinary
+// representation, such as money amounts, identifiers or enums whose PHP form
+// cannot be inferred by reflection.
+//
+// MarshalIgbinary must write exactly one value using the write methods of the
+// Encoder it receives ([Encoder.WriteInt], [Encoder.WriteArrayHeader],
+// [Encoder.EncodeValue], ...):
+//
+//	func (m Money) MarshalIgbinary(e *igbinary.Encoder) error {
+//	    e.WriteObjectHeader("App\\Money", 2)
+//	    e.WriteKey("amount")
+//	    e.WriteInt(m.Cents)
+//	    e.WriteKey("currency")
+//	    e.WriteString(m.Currency)
+//	    return nil
+//	}
+type Marshaler interface {
+	MarshalIgbinary(e *Encoder) error
+}
+
+// Unmarshaler is implemented by types that decode their own igbinary
+// representation. [Decoder.DecodeInto] calls UnmarshalIgbinary with the
+// decoded PHP value whenever it fills a value of such a type, instead of
+// applying the default assignment rules. PHP NULL resets the target to its
+// zero value without calling UnmarshalIgbinary.
+//
+//	func (m *Money) UnmarshalIgbinary(v igbinary.Value) error {
+//	    var raw struct {
+//	        Amount   int64  `igbinary:"amount"`
+//	        Currency string `igbinary:"currency"`
+//	    }
+//	    if err := v.Decode(&raw); err != nil {
+//	        return err
+//	    }
+//	    *m = Money{Cents: raw.Amount, Currency: raw.Currency}
+//	    return nil
+//	}
+type Unmarshaler interface {
+	UnmarshalIgbinary(v Value) error
+}
+
+var (
+	marshalerType   = reflect.TypeFor[Marshaler]()
+	unmarshalerType = reflect.TypeFor[Unmarshaler]()
+)
+
+// encodeMarshaler lets m write its own representation and checks that it
+// wrote a value.
+func (w *writer) encodeMarshaler(m Marshaler) error {
+	if rv := reflect.ValueOf(m); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		w.writeNil()
+		return nil
+	}
+
+	start := len(w.buf)
+	if err := m.MarshalIgbinary(w.enc); err != nil {
+		return fmt.Errorf("igbinary: MarshalIgbinary for %T: %w", m, err)
+	}
+	if w.err != nil {
+		return fmt.Errorf("igbinary: MarshalIgbinary for %T: %w", m, w.err)
+	}
+	if len(w.buf) == start {
+		return fmt.Errorf("%w: MarshalIgbinary for %T wrote no value", ErrUnsupportedValue, m)
+	}
+	return nil
+}
+
+// addrMarshaler returns the Marshaler implemented by a pointer to the
+// addressable value v, such as a struct field with a pointer-receiver
+// MarshalIgbinary method.
+func addrMarshaler(v reflect.Value) (Marshaler, bool) {
+	if v.Kind() == reflect.Pointer || !v.CanAddr() {
+		return nil, false
+	}
+	if !reflect.PointerTo(v.Type()).Implements(marshalerType) {
+		return nil, false
+	}
+	return v.Addr().Interface().(Marshaler), true
+}
+
+// unmarshalerFor returns the Unmarshaler implemented by dst or by a pointer
+// to it.
+func unmarshalerFor(dst reflect.Value) (Unmarshaler, bool) {
+	if dst.Kind() == reflect.Interface || dst.Kind() == reflect.Pointer {
+		return nil, false
+	}
+	if dst.CanAddr() && reflect.PointerTo(dst.Type()).Implements(unmarshalerType) {
+		return dst.Addr().Interface().(Unmarshaler), true
+	}
+	if dst.Type().Implements(unmarshalerType) {
+		return dst.Interface().(Unmarshaler), true
+	}
+	return nil, false
+}
@@ -0,0 +1,165 @@
+package igbinary_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// money is written as a PHP object with integer cents and a currency code.
+type money struct {
+	Cents    int64
+	Currency string
+}
+
+func (m money) MarshalIgbinary(e *igbinary.Encoder) error {
+	e.WriteObjectHeader("App\\Money", 2)
+	e.WriteKey("amount")
+	e.WriteInt(m.Cents)
+	e.WriteKey("currency")
+	e.WriteString(m.Currency)
+	return nil
+}
+
+func (m *money) UnmarshalIgbinary(v igbinary.Value) error {
+	var raw struct {
+		Amount   int64  `igbinary:"amount"`
+		Currency string `igbinary:"currency"`
+	}
+	if err := v.Decode(&raw); err != nil {
+		return err
+	}
+	*m = money{Cents: raw.Amount, Currency: raw.Currency}
+	return nil
+}
+
+// status is an enum stored in PHP as its lower-case name.
+type status int
+
+const (
+	statusActive status = iota + 1
+	statusBanned
+)
+
+func (s status) MarshalIgbinary(e *igbinary.Encoder) error {
+	switch s {
+	case statusActive:
+		e.WriteString("active")
+	case statusBanned:
+		e.WriteString("banned")
+	default:
+		return fmt.Errorf("unknown status %d", int(s))
+	}
+	return nil
+}
+
+func (s *status) UnmarshalIgbinary(v igbinary.Value) error {
+	name, ok := v.Interface().(string)
+	if !ok {
+		return fmt.Errorf("status: expected string, got %T", v.Interface())
+	}
+	switch name {
+	case "active":
+		*s = statusActive
+	case "banned":
+		*s = statusBanned
+	default:
+		return fmt.Errorf("unknown status %q", name)
+	}
+	return nil
+}
+
+type order struct {
+	Total  money  `igbinary:"total"`
+	Status status `igbinary:"status"`
+}
+
+func TestMarshalerWritesCustomRepresentation(t *testing.T) {
+	data, err := igbinary.Marshal(order{Total: money{Cents: 1999, Currency: "EUR"}, Status: statusBanned})
+	assertNoError(t, err)
+
+	val, err := igbinary.Decode(data)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	assertEqualString(t, m["status"], "banned")
+	total := m["total"].(map[string]any)
+	assertEqualString(t, total[igbinary.ClassKey], "App\\Money")
+	assertEqualInt64(t, total["amount"], 1999)
+	assertEqualString(t, total["currency"], "EUR")
+}
+
+func TestUnmarshalerRoundTrip(t *testing.T) {
+	in := order{Total: money{Cents: 500, Currency: "USD"}, Status: statusActive}
+	data, err := igbinary.Marshal(in)
+	assertNoError(t, err)
+
+	var out order
+	assertNoError(t, igbinary.Unmarshal(data, &out))
+	if out != in {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestUnmarshalerErrorIncludesPath(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{"status": "deleted"})
+	assertNoError(t, err)
+
+	var out order
+	err = igbinary.Unmarshal(data, &out)
+	if err == nil || !strings.Contains(err.Error(), "$.status") {
+		t.Errorf("expected error mentioning $.status, got: %v", err)
+	}
+}
+
+func TestMarshalerErrorIsReturned(t *testing.T) {
+	_, err := igbinary.Marshal(status(99))
+	if err == nil || !strings.Contains(err.Error(), "unknown status 99") {
+		t.Errorf("expected marshaler error, got: %v", err)
+	}
+}
+
+type silent struct{}
+
+func (silent) MarshalIgbinary(*igbinary.Encoder) error { return nil }
+
+func TestMarshalerMustWriteAValue(t *testing.T) {
+	_, err := igbinary.Marshal(silent{})
+	if !errors.Is(err, igbinary.ErrUnsupportedValue) {
+		t.Errorf("expected ErrUnsupportedValue, got: %v", err)
+	}
+}
+
+type wrapper struct {
+	Items []int
+}
+
+func (w wrapper) MarshalIgbinary(e *igbinary.Encoder) error {
+	e.WriteArrayHeader(1)
+	e.WriteKey("items")
+	return e.EncodeValue(w.Items)
+}
+
+func TestMarshalerEncodeValue(t *testing.T) {
+	data, err := igbinary.Marshal(wrapper{Items: []int{4, 5}})
+	assertNoError(t, err)
+
+	var out struct {
+		Items []int `igbinary:"items"`
+	}
+	assertNoError(t, igbinary.Unmarshal(data, &out))
+	if len(out.Items) != 2 || out.Items[0] != 4 || out.Items[1] != 5 {
+		t.Errorf("unexpected items: %v", out.Items)
+	}
+}
+
+func TestEncoderWriteOutsideMarshalerPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	igbinary.NewEncoder().WriteNull()
+}
@@ -1,6 +1,11 @@
 package igbinary
 
-import "strconv"
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+)
 
 // NormalizeArrays recursively walks a decoded igbinary value and converts
 // map[string]any whose keys are sequential integers ("0","1","2",…) into
@@ -17,76 +22,395 @@ import "strconv"
 //   - A map whose keys are exactly "0","1",…,"N-1" is converted to a []any of
 //     length N with values in index order, recursively normalized.
 //   - All other maps are left as-is but their values are recursively normalized.
+//   - An [OrderedMap] is converted the same way, but only when its keys are
+//     "0","1",…,"N-1" in that insertion order; otherwise its order is kept.
+//   - A map[Key]any (see [WithKeyKinds]) is converted only when its keys are
+//     the integer keys 0..N-1; string keys such as "0" never form a list.
 //   - Slices have their elements recursively normalized.
-//   - Non-map, non-slice values are returned unchanged.
+//   - Non-map, non-slice values, including *[Reference], are returned
+//     unchanged.
 //
-// This is a pure function — it does not modify the original value when the
-// top-level type is a slice or scalar value. When the top-level type is a map
-// that is NOT sequential, values are normalized in-place for efficiency.
-func NormalizeArrays(v any) any {
-	switch val := v.(type) {
-	case map[string]any:
-		return normalizeMap(val)
-	case []any:
-		return normalizeSlice(val)
-	default:
-		return v
-	}
+// The options [KeepEmptyObjectsAt], [AllowSparseLists], [RequireIntKeys] and
+// [MaxListLength] adjust which arrays become lists.
+//
+// Back-references let decoded arrays and objects appear more than once in a
+// tree, or even inside themselves. A container reached again is normalized
+// only once and its result is shared; one reached again from inside itself
+// is replaced by a *[CycleRef], so the result can always be walked and
+// marshaled to JSON.
+//
+// Maps and slices that are kept are normalized in place for efficiency,
+// which modifies the input. Use [NormalizeArraysCopy] for values that are
+// shared, e.g. cached and read by several goroutines.
+func NormalizeArrays(v any, opts ...NormalizeOption) any {
+	n := newNormalizer(opts)
+	val, _ := n.normalize(v)
+	return val
+}
+
+// NormalizeArraysCopy is like [NormalizeArrays] but never modifies v. Maps
+// and slices that would change are copied first; unchanged subtrees are
+// shared between v and the result.
+func NormalizeArraysCopy(v any, opts ...NormalizeOption) any {
+	n := newNormalizer(opts)
+	n.copy = true
+	val, _ := n.normalize(v)
+	return val
 }
 
 // WithNormalizeArrays enables automatic array normalization after decoding.
-// When enabled, the decoder applies [NormalizeArrays] to the decoded result
-// before returning it, converting PHP sequential arrays (decoded as maps with
-// "0","1",… keys) into Go slices.
+// When enabled, the decoder applies [NormalizeArrays] with opts to the
+// decoded result before returning it, converting PHP sequential arrays
+// (decoded as maps with "0","1",… keys) into Go slices.
 //
 // This is useful when the decoded data will be serialized to JSON, where the
 // distinction between arrays and objects matters.
-func WithNormalizeArrays() Option {
+func WithNormalizeArrays(opts ...NormalizeOption) Option {
 	return func(d *Decoder) {
 		d.normalize = true
+		d.normalizeOpts = opts
+	}
+}
+
+// NormalizeOption configures which arrays [NormalizeArrays] turns into
+// lists.
+type NormalizeOption func(*normalizer)
+
+// KeepEmptyObjectsAt keeps empty arrays at the given paths as maps, so that
+// they marshal to JSON as {} instead of []. Paths are relative to the
+// normalized value, and a "*" key matches any key:
+//
+//	igbinary.KeepEmptyObjectsAt(
+//	    igbinary.NewPath("meta"),
+//	    igbinary.NewPath("items", "*", "attributes"),
+//	)
+func KeepEmptyObjectsAt(paths ...Path) NormalizeOption {
+	return func(n *normalizer) {
+		n.keepEmpty = append(n.keepEmpty, paths...)
+	}
+}
+
+// AllowSparseLists converts arrays with non-negative integer keys that are
+// not contiguous, such as the result of PHP's array_filter(), into lists
+// with nil for the missing indexes. To keep a few large keys from
+// allocating huge slices, such an array only becomes a list if at least
+// half of the list's elements are present.
+func AllowSparseLists() NormalizeOption {
+	return func(n *normalizer) {
+		n.sparse = true
+	}
+}
+
+// RequireIntKeys only converts arrays whose keys PHP stored as integers, so
+// ['0' => 'a'] built with string keys stays a map. Maps decoded without
+// [WithOrderedMaps] or [WithKeyKinds] do not record the kind of their keys;
+// map[string]any is not affected by this option.
+func RequireIntKeys() NormalizeOption {
+	return func(n *normalizer) {
+		n.intKeys = true
+	}
+}
+
+// MaxListLength keeps arrays that would become lists longer than max as
+// maps.
+func MaxListLength(max int) NormalizeOption {
+	return func(n *normalizer) {
+		n.maxLen = max
+	}
+}
+
+// CycleRef stands in for an array or object that contains itself, in the
+// result of [NormalizeArrays]. Path leads from the root of the normalized
+// value to the container it refers to.
+type CycleRef struct {
+	Path Path
+}
+
+// MarshalJSON encodes the cycle as a JSON reference, {"$ref":"$.path"}.
+func (c *CycleRef) MarshalJSON() ([]byte, error) {
+	return []byte(`{"$ref":` + strconv.Quote(c.Path.String()) + `}`), nil
+}
+
+// normalizer holds the policy and state of one NormalizeArrays call.
+type normalizer struct {
+	copy      bool // never modify the input
+	keepEmpty []Path
+	sparse    bool
+	intKeys   bool
+	maxLen    int // 0 for no limit
+
+	seen map[containerID]*normalizedContainer
+	path []Key // keys leading to the value being normalized
+}
+
+func newNormalizer(opts []NormalizeOption) *normalizer {
+	n := &normalizer{}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// containerID identifies a map or slice by its backing storage.
+type containerID struct {
+	ptr uintptr
+	len int
+}
+
+// normalizedContainer is the outcome of normalizing a container, or the
+// marker of one still being normalized.
+type normalizedContainer struct {
+	val     any
+	changed bool
+	done    bool
+	depth   int // len(path) at the container
+}
+
+// normalize returns the normalized form of v and whether it is a different
+// value than v, which the container holding v must then be updated with.
+// Containers normalized in place are not different values.
+func (n *normalizer) normalize(v any) (any, bool) {
+	var id containerID
+	var size int
+	switch val := v.(type) {
+	case map[string]any:
+		id.ptr, size = reflect.ValueOf(val).Pointer(), len(val)
+	case map[Key]any:
+		id.ptr, size = reflect.ValueOf(val).Pointer(), len(val)
+	case *OrderedMap:
+		if val == nil {
+			return v, false
+		}
+		id.ptr, size = reflect.ValueOf(val).Pointer(), val.Len()
+	case []any:
+		if len(val) == 0 {
+			return v, false
+		}
+		id = containerID{ptr: reflect.ValueOf(val).Pointer(), len: len(val)}
+	default:
+		return v, false
+	}
+
+	// Empty PHP arrays become empty lists. Their result depends on where
+	// they are, so it is not shared.
+	if _, ok := v.([]any); !ok && size == 0 {
+		if n.keepEmptyHere() {
+			return v, false
+		}
+		return []any{}, true
+	}
+
+	if c, ok := n.seen[id]; ok {
+		if !c.done {
+			return n.cycleRef(c.depth), true
+		}
+		return c.val, c.changed
+	}
+	if n.seen == nil {
+		n.seen = make(map[containerID]*normalizedContainer)
+	}
+	c := &normalizedContainer{depth: len(n.path)}
+	n.seen[id] = c
+
+	switch val := v.(type) {
+	case map[string]any:
+		c.val, c.changed = n.normalizeMap(val)
+	case *OrderedMap:
+		c.val, c.changed = n.normalizeOrderedMap(val)
+	case map[Key]any:
+		c.val, c.changed = n.normalizeKeyMap(val)
+	case []any:
+		c.val, c.changed = n.normalizeSlice(val)
+	}
+	c.done = true
+	return c.val, c.changed
+}
+
+// entry normalizes the value stored under key.
+func (n *normalizer) entry(key Key, v any) (any, bool) {
+	n.path = append(n.path, key)
+	val, changed := n.normalize(v)
+	n.path = n.path[:len(n.path)-1]
+	return val, changed
+}
+
+// cycleRef returns the marker for the container at depth of the path.
+func (n *normalizer) cycleRef(depth int) *CycleRef {
+	keys := make([]string, depth)
+	for i, k := range n.path[:depth] {
+		keys[i] = k.String()
+	}
+	return &CycleRef{Path: NewPath(keys...)}
+}
+
+// keepEmptyHere reports whether an empty array at the current path stays a
+// map.
+func (n *normalizer) keepEmptyHere() bool {
+	for _, p := range n.keepEmpty {
+		if len(p.segments) != len(n.path) {
+			continue
+		}
+		match := true
+		for i, seg := range p.segments {
+			if seg.str != "*" && !seg.matches(n.path[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
 	}
+	return false
 }
 
-// normalizeMap checks whether the map has sequential integer keys (0..n-1).
+// listLength returns the length of the list that an array of size entries
+// with distinct non-negative integer keys up to last becomes, or -1 if it
+// stays a map.
+func (n *normalizer) listLength(size int, last int64) int {
+	length := last + 1
+	if length > int64(size) && (!n.sparse || length > 2*int64(size)) {
+		return -1
+	}
+	if n.maxLen > 0 && length > int64(n.maxLen) {
+		return -1
+	}
+	return int(length)
+}
+
+// normalizeMap checks whether the map's keys are integers that form a list.
 // If so, it returns a []any slice with the values in order. Otherwise, it
-// returns the same map with values recursively normalized.
-func normalizeMap(m map[string]any) any {
-	n := len(m)
+// returns the map with values recursively normalized.
+func (n *normalizer) normalizeMap(m map[string]any) (any, bool) {
+	length := -1
+	last := int64(-1)
+	for k := range m {
+		i, ok := parseIntKey(k)
+		if !ok || i < 0 {
+			last = -1
+			break
+		}
+		last = max(last, i)
+	}
+	if last >= 0 {
+		length = n.listLength(len(m), last)
+	}
 
-	// Empty map → empty slice (PHP empty array [] serialized as igbinary array
-	// with zero entries decodes as map[string]any{}).
-	if n == 0 {
-		return []any{}
+	if length >= 0 {
+		slice := make([]any, length)
+		for k, v := range m {
+			i, _ := parseIntKey(k)
+			slice[i], _ = n.entry(IntKey(i), v)
+		}
+		return slice, true
 	}
 
-	// Check whether every key is a sequential integer from 0 to n-1.
-	isSequential := true
-	for i := 0; i < n; i++ {
-		if _, ok := m[strconv.Itoa(i)]; !ok {
-			isSequential = false
+	// Not a list — normalize values, in place unless copying.
+	out, copied := m, false
+	for k, v := range m {
+		val, changed := n.entry(StringKey(k), v)
+		if !changed {
+			continue
+		}
+		if n.copy && !copied {
+			out, copied = maps.Clone(m), true
+		}
+		out[k] = val
+	}
+	return out, copied
+}
+
+// normalizeOrderedMap is normalizeMap for an OrderedMap. PHP only produces a
+// list when the keys were inserted in index order, so the order is checked
+// as well as the key set.
+func (n *normalizer) normalizeOrderedMap(m *OrderedMap) (any, bool) {
+	length := -1
+	last := int64(-1)
+	for _, e := range m.entries {
+		i, ok := parseIntKey(e.Key)
+		if !ok || i <= last || n.intKeys && !e.IsInt {
+			last = -1
 			break
 		}
+		last = i
+	}
+	if last >= 0 {
+		length = n.listLength(m.Len(), last)
+	}
+
+	if length >= 0 {
+		slice := make([]any, length)
+		for _, e := range m.entries {
+			i, _ := parseIntKey(e.Key)
+			slice[i], _ = n.entry(IntKey(i), e.Value)
+		}
+		return slice, true
 	}
 
-	if isSequential {
-		slice := make([]any, n)
-		for i := 0; i < n; i++ {
-			slice[i] = NormalizeArrays(m[strconv.Itoa(i)])
+	out, copied := m, false
+	for i, e := range m.entries {
+		val, changed := n.entry(e.PHPKey(), e.Value)
+		if !changed {
+			continue
+		}
+		if n.copy && !copied {
+			out, copied = &OrderedMap{entries: slices.Clone(m.entries), index: maps.Clone(m.index)}, true
 		}
-		return slice
+		out.entries[i].Value = val
 	}
+	return out, copied
+}
 
-	// Not sequential — normalize values in-place.
+// normalizeKeyMap is normalizeMap for a map[Key]any, which knows the kind of
+// every key and so does not need to parse strings.
+func (n *normalizer) normalizeKeyMap(m map[Key]any) (any, bool) {
+	length := -1
+	last := int64(-1)
+	for k := range m {
+		if !k.IsInt || k.Int < 0 {
+			last = -1
+			break
+		}
+		last = max(last, k.Int)
+	}
+	if last >= 0 {
+		length = n.listLength(len(m), last)
+	}
+
+	if length >= 0 {
+		slice := make([]any, length)
+		for k, v := range m {
+			slice[k.Int], _ = n.entry(k, v)
+		}
+		return slice, true
+	}
+
+	out, copied := m, false
 	for k, v := range m {
-		m[k] = NormalizeArrays(v)
+		val, changed := n.entry(k, v)
+		if !changed {
+			continue
+		}
+		if n.copy && !copied {
+			out, copied = maps.Clone(m), true
+		}
+		out[k] = val
 	}
-	return m
+	return out, copied
 }
 
 // normalizeSlice recursively normalizes each element of a slice.
-func normalizeSlice(s []any) []any {
+func (n *normalizer) normalizeSlice(s []any) (any, bool) {
+	out, copied := s, false
 	for i, v := range s {
-		s[i] = NormalizeArrays(v)
+		val, changed := n.entry(IntKey(int64(i)), v)
+		if !changed {
+			continue
+		}
+		if n.copy && !copied {
+			out, copied = slices.Clone(s), true
+		}
+		out[i] = val
 	}
-	return s
+	return out, copied
 }
@@ -1,6 +1,7 @@
 package igbinary
 
 import (
+	"encoding/json"
 	"strconv"
 	"testing"
 )
@@ -310,3 +311,218 @@ func TestWithNormalizeArrays_AssocArrayUnchanged(t *testing.T) {
 		t.Errorf("expected name=test, got %v", m["name"])
 	}
 }
+
+func TestNormalizeArrays_SelfReferencingArray(t *testing.T) {
+	// An array holding a back-reference to itself: [0 => 1, 1 => <array ref 0>]
+	data := []byte{
+		0x00, 0x00, 0x00, 0x02, // header
+		0x14, 0x02, // array8, 2 entries
+		0x06, 0x00, 0x06, 0x01, // 0 => 1
+		0x06, 0x01, 0x01, 0x00, // 1 => array ref 0
+	}
+	val, err := NewDecoder(WithNormalizeArrays()).Decode(data)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	s, ok := val.([]any)
+	if !ok || len(s) != 2 {
+		t.Fatalf("expected []any of length 2, got %#v", val)
+	}
+	cycle, ok := s[1].(*CycleRef)
+	if !ok {
+		t.Fatalf("expected *CycleRef, got %T", s[1])
+	}
+	if got := cycle.Path.String(); got != "$" {
+		t.Errorf("expected path $, got %s", got)
+	}
+
+	out, err := json.Marshal(val)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if string(out) != `[1,{"$ref":"$"}]` {
+		t.Errorf("unexpected JSON %s", out)
+	}
+}
+
+func TestNormalizeArrays_NestedCycle(t *testing.T) {
+	inner := map[string]any{"name": "node"}
+	inner["self"] = inner
+	root := map[string]any{"nodes": map[string]any{"0": inner}}
+
+	result := NormalizeArrays(root).(map[string]any)
+	node := result["nodes"].([]any)[0].(map[string]any)
+	cycle, ok := node["self"].(*CycleRef)
+	if !ok {
+		t.Fatalf("expected *CycleRef, got %T", node["self"])
+	}
+	if got := cycle.Path.String(); got != "$.nodes[0]" {
+		t.Errorf("expected path $.nodes[0], got %s", got)
+	}
+}
+
+func TestNormalizeArrays_SharedContainer(t *testing.T) {
+	shared := map[string]any{"0": "a", "1": "b"}
+	result := NormalizeArrays(map[string]any{"x": shared, "y": shared}).(map[string]any)
+	x, y := result["x"].([]any), result["y"].([]any)
+	if len(x) != 2 || &x[0] != &y[0] {
+		t.Errorf("expected one shared slice, got %v and %v", x, y)
+	}
+}
+
+func TestNormalizeArraysCopy_DoesNotMutate(t *testing.T) {
+	list := map[string]any{"0": "x", "1": "y"}
+	assoc := map[string]any{"k": "v"}
+	input := map[string]any{"list": list, "assoc": assoc}
+
+	result, ok := NormalizeArraysCopy(input).(map[string]any)
+	if !ok {
+		t.Fatalf("expected map[string]any, got %T", result)
+	}
+	if _, ok := input["list"].(map[string]any); !ok {
+		t.Errorf("input was modified: list is %T", input["list"])
+	}
+	if s, ok := result["list"].([]any); !ok || len(s) != 2 {
+		t.Errorf("expected list as []any, got %#v", result["list"])
+	}
+	// Unchanged subtrees are shared, not copied.
+	result["assoc"].(map[string]any)["k2"] = "v2"
+	if assoc["k2"] != "v2" {
+		t.Error("expected the unchanged map to be shared")
+	}
+}
+
+func TestNormalizeArraysCopy_OrderedMapAndSlice(t *testing.T) {
+	m := NewOrderedMap(2)
+	m.Set("name", "a")
+	m.Set("tags", map[string]any{"0": "t"})
+	s := []any{m}
+
+	result := NormalizeArraysCopy(s).([]any)
+	if result[0] == any(m) {
+		t.Fatal("expected the ordered map to be copied")
+	}
+	if tags, _ := result[0].(*OrderedMap).Get("tags"); !isSlice(tags) {
+		t.Errorf("expected tags as []any, got %T", tags)
+	}
+	if tags, _ := m.Get("tags"); isSlice(tags) || s[0] != any(m) {
+		t.Error("input was modified")
+	}
+}
+
+func TestNormalizeArraysCopy_Cycle(t *testing.T) {
+	m := map[string]any{"a": map[string]any{"0": 1}}
+	m["self"] = m
+
+	result := NormalizeArraysCopy(m).(map[string]any)
+	if _, ok := result["self"].(*CycleRef); !ok {
+		t.Errorf("expected *CycleRef, got %T", result["self"])
+	}
+	if _, ok := m["self"].(map[string]any); !ok {
+		t.Errorf("input was modified: self is %T", m["self"])
+	}
+}
+
+func isSlice(v any) bool {
+	_, ok := v.([]any)
+	return ok
+}
+
+func TestNormalizeArrays_KeepEmptyObjectsAt(t *testing.T) {
+	input := map[string]any{
+		"meta": map[string]any{},
+		"items": map[string]any{
+			"0": map[string]any{"attributes": map[string]any{}},
+			"1": map[string]any{"attributes": map[string]any{}},
+		},
+		"tags": map[string]any{},
+	}
+	result := NormalizeArrays(input, KeepEmptyObjectsAt(
+		NewPath("meta"),
+		NewPath("items", "*", "attributes"),
+	)).(map[string]any)
+
+	if _, ok := result["meta"].(map[string]any); !ok {
+		t.Errorf("expected meta to stay a map, got %T", result["meta"])
+	}
+	for i, item := range result["items"].([]any) {
+		attrs := item.(map[string]any)["attributes"]
+		if _, ok := attrs.(map[string]any); !ok {
+			t.Errorf("expected items[%d].attributes to stay a map, got %T", i, attrs)
+		}
+	}
+	if _, ok := result["tags"].([]any); !ok {
+		t.Errorf("expected tags as []any, got %T", result["tags"])
+	}
+}
+
+func TestNormalizeArrays_AllowSparseLists(t *testing.T) {
+	sparse := func() map[string]any { return map[string]any{"0": "a", "2": "c", "3": "d"} }
+
+	if _, ok := NormalizeArrays(sparse()).(map[string]any); !ok {
+		t.Error("expected sparse array to stay a map by default")
+	}
+	s, ok := NormalizeArrays(sparse(), AllowSparseLists()).([]any)
+	if !ok || len(s) != 4 || s[0] != "a" || s[1] != nil || s[2] != "c" || s[3] != "d" {
+		t.Errorf("expected [a <nil> c d], got %#v", s)
+	}
+
+	// Less than half filled.
+	input := map[string]any{"0": "a", "100": "b"}
+	if _, ok := NormalizeArrays(input, AllowSparseLists()).(map[string]any); !ok {
+		t.Error("expected mostly empty array to stay a map")
+	}
+
+	keyed := map[Key]any{IntKey(1): "b"}
+	if s, ok := NormalizeArrays(keyed, AllowSparseLists()).([]any); !ok || len(s) != 2 || s[1] != "b" {
+		t.Errorf("expected [<nil> b], got %#v", s)
+	}
+}
+
+func TestNormalizeArrays_RequireIntKeys(t *testing.T) {
+	strKeys := NewOrderedMap(2)
+	strKeys.Set("0", "a")
+	strKeys.Set("1", "b")
+	intKeys := NewOrderedMap(2)
+	intKeys.SetKey(IntKey(0), "a")
+	intKeys.SetKey(IntKey(1), "b")
+
+	if _, ok := NormalizeArrays(strKeys, RequireIntKeys()).(*OrderedMap); !ok {
+		t.Error("expected string keys to stay a map")
+	}
+	if _, ok := NormalizeArrays(intKeys, RequireIntKeys()).([]any); !ok {
+		t.Error("expected integer keys to become a list")
+	}
+	plain := map[string]any{"0": "a"}
+	if _, ok := NormalizeArrays(plain, RequireIntKeys()).([]any); !ok {
+		t.Error("expected map[string]any to be unaffected")
+	}
+}
+
+func TestNormalizeArrays_MaxListLength(t *testing.T) {
+	input := map[string]any{"0": "a", "1": "b", "2": "c"}
+	if _, ok := NormalizeArrays(input, MaxListLength(2)).(map[string]any); !ok {
+		t.Error("expected array longer than the limit to stay a map")
+	}
+	if _, ok := NormalizeArrays(input, MaxListLength(3)).([]any); !ok {
+		t.Error("expected array within the limit to become a list")
+	}
+}
+
+func TestWithNormalizeArrays_Options(t *testing.T) {
+	// [0 => 10, 2 => 20], as left by unset($a[1])
+	data := []byte{
+		0x00, 0x00, 0x00, 0x02, // header
+		0x14, 0x02, // array8, 2 entries
+		0x06, 0x00, 0x06, 0x0A, // 0 => 10
+		0x06, 0x02, 0x06, 0x14, // 2 => 20
+	}
+	val, err := NewDecoder(WithNormalizeArrays(AllowSparseLists())).Decode(data)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	s, ok := val.([]any)
+	if !ok || len(s) != 3 || s[0] != int64(10) || s[1] != nil || s[2] != int64(20) {
+		t.Errorf("expected [10 <nil> 20], got %#v", val)
+	}
+}
@@ -0,0 +1,151 @@
+package igbinary
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// OrderedMap is a PHP array (or object property table) that preserves the
+// insertion order of its entries, as PHP arrays do.
+//
+// Decoders created with [WithOrderedMaps] return *OrderedMap instead of
+// map[string]any. Objects are represented the same way, with the class name
+// stored as the first entry under [ClassKey].
+//
+// The zero value is an empty map ready to use. An OrderedMap is not safe for
+// concurrent mutation.
+type OrderedMap struct {
+	entries []Entry
+	index   map[string]int
+}
+
+// Entry is a single key/value pair of an [OrderedMap].
+type Entry struct {
+	Key   string
+	Value any
+
+	// IsInt reports that PHP stored Key as an integer key. Decoded maps
+	// always record it; entries added with [OrderedMap.Set] leave it unset.
+	IsInt bool
+}
+
+// PHPKey returns the entry's key together with its kind.
+func (e Entry) PHPKey() Key {
+	if e.IsInt {
+		if i, err := strconv.ParseInt(e.Key, 10, 64); err == nil {
+			return IntKey(i)
+		}
+	}
+	return StringKey(e.Key)
+}
+
+// NewOrderedMap creates an empty OrderedMap with room for capacity entries.
+func NewOrderedMap(capacity int) *OrderedMap {
+	return &OrderedMap{
+		entries: make([]Entry, 0, capacity),
+		index:   make(map[string]int, capacity),
+	}
+}
+
+// Len returns the number of entries.
+func (m *OrderedMap) Len() int {
+	return len(m.entries)
+}
+
+// Get returns the value stored under key.
+func (m *OrderedMap) Get(key string) (any, bool) {
+	i, ok := m.index[key]
+	if !ok {
+		return nil, false
+	}
+	return m.entries[i].Value, true
+}
+
+// Set stores value under key. A new key is appended at the end; an existing
+// key keeps its position and has its value replaced, as in PHP.
+func (m *OrderedMap) Set(key string, value any) {
+	if i, ok := m.index[key]; ok {
+		m.entries[i].Value = value
+		return
+	}
+	if m.index == nil {
+		m.index = make(map[string]int)
+	}
+	m.index[key] = len(m.entries)
+	m.entries = append(m.entries, Entry{Key: key, Value: value})
+}
+
+// SetKey is like Set but also records the kind of k, so that integer keys
+// are written back as integers by [Encode].
+func (m *OrderedMap) SetKey(k Key, value any) {
+	key := k.String()
+	m.Set(key, value)
+	m.entries[m.index[key]].IsInt = k.IsInt
+}
+
+// Delete removes key, preserving the order of the remaining entries.
+func (m *OrderedMap) Delete(key string) {
+	i, ok := m.index[key]
+	if !ok {
+		return
+	}
+	delete(m.index, key)
+	m.entries = append(m.entries[:i], m.entries[i+1:]...)
+	for j := i; j < len(m.entries); j++ {
+		m.index[m.entries[j].Key] = j
+	}
+}
+
+// At returns the i-th entry in insertion order.
+func (m *OrderedMap) At(i int) Entry {
+	return m.entries[i]
+}
+
+// Keys returns the keys in insertion order.
+func (m *OrderedMap) Keys() []string {
+	keys := make([]string, len(m.entries))
+	for i, e := range m.entries {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+// Entries returns the entries in insertion order. The returned slice is
+// shared with the map and must not be modified.
+func (m *OrderedMap) Entries() []Entry {
+	return m.entries
+}
+
+// MarshalJSON encodes the map as a JSON object with keys in insertion order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range m.entries {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// WithOrderedMaps makes the decoder return PHP arrays and object properties
+// as *[OrderedMap], preserving the order in which PHP stored the entries.
+// [NormalizeArrays] and JSON marshaling both respect that order.
+func WithOrderedMaps() Option {
+	return func(d *Decoder) {
+		d.ordered = true
+	}
+}
@@ -0,0 +1,140 @@
+package igbinary_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// unsortedArray is ["z" => 1, "a" => 2, "m" => 3].
+var unsortedArray = makePayload(
+	0x14, 0x03,
+	0x11, 0x01, 'z', 0x06, 0x01,
+	0x11, 0x01, 'a', 0x06, 0x02,
+	0x11, 0x01, 'm', 0x06, 0x03,
+)
+
+func decodeOrdered(t *testing.T, data []byte) *igbinary.OrderedMap {
+	t.Helper()
+	val, err := igbinary.NewDecoder(igbinary.WithOrderedMaps()).Decode(data)
+	assertNoError(t, err)
+	m, ok := val.(*igbinary.OrderedMap)
+	if !ok {
+		t.Fatalf("expected *OrderedMap, got %T", val)
+	}
+	return m
+}
+
+func TestOrderedMapsPreserveArrayOrder(t *testing.T) {
+	m := decodeOrdered(t, unsortedArray)
+	if got := m.Keys(); !reflect.DeepEqual(got, []string{"z", "a", "m"}) {
+		t.Errorf("unexpected key order: %v", got)
+	}
+	v, ok := m.Get("a")
+	if !ok {
+		t.Fatal("expected key a")
+	}
+	assertEqualInt64(t, v, 2)
+}
+
+func TestOrderedMapsObjectClassFirst(t *testing.T) {
+	// object(Foo) { b => 1, a => 2 }
+	data := makePayload(
+		0x17, 0x03, 'F', 'o', 'o',
+		0x14, 0x02,
+		0x11, 0x01, 'b', 0x06, 0x01,
+		0x11, 0x01, 'a', 0x06, 0x02,
+	)
+	m := decodeOrdered(t, data)
+	if got := m.Keys(); !reflect.DeepEqual(got, []string{igbinary.ClassKey, "b", "a"}) {
+		t.Errorf("unexpected key order: %v", got)
+	}
+	assertEqualString(t, m.At(0).Value, "Foo")
+}
+
+func TestOrderedMapMarshalJSON(t *testing.T) {
+	out, err := json.Marshal(decodeOrdered(t, unsortedArray))
+	assertNoError(t, err)
+	if string(out) != `{"z":1,"a":2,"m":3}` {
+		t.Errorf("unexpected JSON: %s", out)
+	}
+}
+
+func TestOrderedMapSetAndDelete(t *testing.T) {
+	m := igbinary.NewOrderedMap(0)
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Set("a", 4) // existing key keeps its position
+	m.Delete("b")
+	if got := m.Keys(); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("unexpected keys: %v", got)
+	}
+	if v, _ := m.Get("a"); v != 4 {
+		t.Errorf("expected a=4, got %v", v)
+	}
+	if v, _ := m.Get("c"); v != 3 {
+		t.Errorf("expected c=3 after delete, got %v", v)
+	}
+}
+
+func TestOrderedMapZeroValue(t *testing.T) {
+	var m igbinary.OrderedMap
+	m.Set("x", 1)
+	if m.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", m.Len())
+	}
+}
+
+func TestNormalizeArraysOrderedMap(t *testing.T) {
+	list := igbinary.NewOrderedMap(2)
+	list.Set("0", "a")
+	list.Set("1", "b")
+	if got := igbinary.NormalizeArrays(list); !reflect.DeepEqual(got, []any{"a", "b"}) {
+		t.Errorf("expected list, got %#v", got)
+	}
+
+	// Same keys out of order are not a PHP list.
+	swapped := igbinary.NewOrderedMap(2)
+	swapped.Set("1", "b")
+	swapped.Set("0", "a")
+	if _, ok := igbinary.NormalizeArrays(swapped).(*igbinary.OrderedMap); !ok {
+		t.Error("expected out-of-order keys to stay an OrderedMap")
+	}
+}
+
+func TestEncodeOrderedMapRoundTrip(t *testing.T) {
+	m := decodeOrdered(t, unsortedArray)
+	data, err := igbinary.Encode(m)
+	assertNoError(t, err)
+	if !reflect.DeepEqual(data, unsortedArray) {
+		t.Errorf("round trip mismatch:\n got %x\nwant %x", data, unsortedArray)
+	}
+}
+
+func TestEncodeOrderedMapObject(t *testing.T) {
+	m := igbinary.NewOrderedMap(2)
+	m.Set(igbinary.ClassKey, "Foo")
+	m.Set("x", int64(1))
+	data, err := igbinary.Encode(m)
+	assertNoError(t, err)
+
+	want := makePayload(0x17, 0x03, 'F', 'o', 'o', 0x14, 0x01, 0x11, 0x01, 'x', 0x06, 0x01)
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %x, want %x", data, want)
+	}
+}
+
+func TestUnmarshalFromOrderedMode(t *testing.T) {
+	var out struct {
+		Z int `igbinary:"z"`
+		M int `igbinary:"m"`
+	}
+	dec := igbinary.NewDecoder(igbinary.WithOrderedMaps())
+	assertNoError(t, dec.DecodeInto(unsortedArray, &out))
+	if out.Z != 1 || out.M != 3 {
+		t.Errorf("unexpected result: %+v", out)
+	}
+}
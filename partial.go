@@ -0,0 +1,69 @@
+package igbinary
+
+import "errors"
+
+// Unreadable stands in a tree returned by [Decoder.DecodePartial] for a
+// value that could not be decoded.
+type Unreadable struct {
+	Err *DecodeError
+}
+
+// DecodePartial decodes as much of data as possible using default options.
+// See [Decoder.DecodePartial].
+func DecodePartial(data []byte) (any, []*DecodeError) {
+	return defaultDecoder.DecodePartial(data)
+}
+
+// DecodePartial decodes as much of data as possible, for salvaging truncated
+// or corrupt payloads. Instead of failing, it returns the tree decoded so
+// far, with an *[Unreadable] in place of each value that failed, along with
+// every error it recovered from:
+//
+//	val, errs := dec.DecodePartial(data)
+//	for _, err := range errs {
+//	    log.Printf("lost %s: %v", err.PathString(), err)
+//	}
+//
+// igbinary has no markers to resynchronize on, so after most errors the
+// rest of the payload cannot be read: the failed value becomes Unreadable
+// and the arrays and objects enclosing it keep the entries read before it.
+// Errors that leave the position intact, such as a string or value
+// back-reference out of range or a registered class that fails to
+// convert, only replace the value concerned and decoding continues.
+//
+// The errors are nil if data decoded completely.
+func (d *Decoder) DecodePartial(data []byte) (any, []*DecodeError) {
+	r, err := d.newReader(data)
+	if err != nil {
+		var de *DecodeError
+		errors.As(err, &de)
+		return nil, []*DecodeError{de}
+	}
+	r.partial = true
+
+	val, err := r.decodeValue()
+	if err != nil {
+		val = r.salvage(err)
+	}
+	if d.normalize {
+		val = NormalizeArrays(val, d.normalizeOpts...)
+	}
+	return val, r.errs
+}
+
+// salvage records err, from a value that failed to decode, and returns the
+// placeholder for that value.
+func (r *reader) salvage(err error) *Unreadable {
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		// Errors from outside the decoder, such as a registered class
+		// rejecting the object, come after the value was read completely.
+		de = newError(err, r.pos, "")
+		de.resumable = true
+	}
+	if !de.resumable {
+		r.broken = true
+	}
+	r.errs = append(r.errs, de)
+	return &Unreadable{Err: de}
+}
@@ -0,0 +1,89 @@
+package igbinary_test
+
+import (
+	"errors"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+func TestDecodePartialComplete(t *testing.T) {
+	val, errs := igbinary.DecodePartial(makePayload(0x06, 0x2A))
+	if errs != nil {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	assertEqualInt64(t, val, 42)
+}
+
+func TestDecodePartialTruncated(t *testing.T) {
+	val, errs := igbinary.DecodePartial(truncatedOrder)
+	if len(errs) != 1 || !errors.Is(errs[0], igbinary.ErrUnexpectedEnd) {
+		t.Fatalf("expected one unexpected end error, got %v", errs)
+	}
+	if got := errs[0].PathString(); got != "$.orders[0].customer.address" {
+		t.Errorf("expected path $.orders[0].customer.address, got %s", got)
+	}
+
+	order := val.(map[string]any)["orders"].(map[string]any)["0"].(map[string]any)
+	assertEqualString(t, order[igbinary.ClassKey], "Order")
+	address := order["customer"].(map[string]any)["address"]
+	if u, ok := address.(*igbinary.Unreadable); !ok || u.Err != errs[0] {
+		t.Errorf("expected an Unreadable address, got %#v", address)
+	}
+}
+
+func TestDecodePartialKeepsEarlierEntries(t *testing.T) {
+	// ["a" => 1, "b" => <unknown type 0xFF>, "c" => 3]
+	data := makePayload(0x14, 0x03,
+		0x11, 0x01, 'a', 0x06, 0x01,
+		0x11, 0x01, 'b', 0xFF,
+		0x11, 0x01, 'c', 0x06, 0x03,
+	)
+	val, errs := igbinary.DecodePartial(data)
+	if len(errs) != 1 || !errors.Is(errs[0], igbinary.ErrUnknownType) {
+		t.Fatalf("expected one unknown type error, got %v", errs)
+	}
+	m := val.(map[string]any)
+	assertEqualInt64(t, m["a"], 1)
+	if _, ok := m["b"].(*igbinary.Unreadable); !ok {
+		t.Errorf("expected b to be Unreadable, got %#v", m["b"])
+	}
+	if _, ok := m["c"]; ok {
+		t.Error("expected decoding to stop after the unknown type")
+	}
+}
+
+func TestDecodePartialResumes(t *testing.T) {
+	// [0 => string ID 5, 1 => [0 => array ref 9], 2 => "ok"]
+	data := makePayload(0x14, 0x03,
+		0x06, 0x00, 0x0E, 0x05,
+		0x06, 0x01, 0x14, 0x01, 0x06, 0x00, 0x01, 0x09,
+		0x06, 0x02, 0x11, 0x02, 'o', 'k',
+	)
+	val, errs := igbinary.DecodePartial(data)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if !errors.Is(errs[0], igbinary.ErrStringIDOutOfRange) || errs[0].PathString() != "$[0]" {
+		t.Errorf("unexpected first error %v at %s", errs[0], errs[0].PathString())
+	}
+	if !errors.Is(errs[1], igbinary.ErrValueRefOutOfRange) || errs[1].PathString() != "$[1][0]" {
+		t.Errorf("unexpected second error %v at %s", errs[1], errs[1].PathString())
+	}
+	assertEqualString(t, val.(map[string]any)["2"], "ok")
+}
+
+func TestDecodePartialRoot(t *testing.T) {
+	val, errs := igbinary.DecodePartial(makePayload(0xFF))
+	if len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", errs)
+	}
+	if _, ok := val.(*igbinary.Unreadable); !ok {
+		t.Errorf("expected Unreadable root, got %#v", val)
+	}
+
+	val, errs = igbinary.DecodePartial([]byte{0x01})
+	if val != nil || len(errs) != 1 || !errors.Is(errs[0], igbinary.ErrDataTooShort) {
+		t.Errorf("expected data too short, got %v, %v", val, errs)
+	}
+}
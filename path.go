@@ -0,0 +1,233 @@
+package igbinary
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Get returns the value at the path of array keys or property names in
+// data, decoding nothing but that value:
+//
+//	id, err := igbinary.Get(data, "user", "id") // $data['user']['id']
+//
+// Entries before the target are skipped at the byte level, which makes Get
+// much cheaper than [Decode] for reading a few fields of a large payload.
+// Integer keys are given in decimal ("0", "12"); the class name of an
+// object is available under [ClassKey]. Get returns [ErrPathNotFound] when
+// the path does not exist.
+//
+// To look up the same path repeatedly, compile it once with [NewPath].
+func Get(data []byte, keys ...string) (any, error) {
+	return defaultDecoder.Get(data, NewPath(keys...))
+}
+
+// Path is a compiled sequence of array keys or property names leading to a
+// value inside an igbinary payload. Compiling a path parses its integer
+// keys once, so that looking it up compares keys without allocating.
+//
+// The zero Path refers to the root value.
+type Path struct {
+	segments []pathSegment
+}
+
+// pathSegment is one key of a Path.
+type pathSegment struct {
+	str   string
+	int   int64
+	isInt bool // str is a canonical integer and matches integer key int
+}
+
+// NewPath compiles a path from its keys.
+func NewPath(keys ...string) Path {
+	p := Path{segments: make([]pathSegment, len(keys))}
+	for i, k := range keys {
+		p.segments[i].str = k
+		p.segments[i].int, p.segments[i].isInt = parseIntKey(k)
+	}
+	return p
+}
+
+// ParsePath compiles a path written in the notation used by error messages:
+//
+//	$.orders[12].customer["first name"]
+//
+// The leading "$" is optional. Names after a dot must be identifiers; other
+// keys are written in brackets, either as integers or as quoted strings.
+func ParsePath(expr string) (Path, error) {
+	var keys []string
+	rest := strings.TrimPrefix(expr, "$")
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := 1
+			for end < len(rest) && rest[end] != '.' && rest[end] != '[' {
+				end++
+			}
+			name := rest[1:end]
+			if !isIdentifier(name) {
+				return Path{}, fmt.Errorf("igbinary: invalid path %q: bad name %q", expr, name)
+			}
+			keys = append(keys, name)
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if len(rest) > 1 && rest[1] == '"' {
+				quoted, err := strconv.QuotedPrefix(rest[1:])
+				if err != nil {
+					return Path{}, fmt.Errorf("igbinary: invalid path %q: %w", expr, err)
+				}
+				end = 1 + len(quoted)
+				if end >= len(rest) || rest[end] != ']' {
+					return Path{}, fmt.Errorf("igbinary: invalid path %q: missing ]", expr)
+				}
+				key, _ := strconv.Unquote(quoted)
+				keys = append(keys, key)
+			} else {
+				if end < 0 {
+					return Path{}, fmt.Errorf("igbinary: invalid path %q: missing ]", expr)
+				}
+				if _, ok := parseIntKey(rest[1:end]); !ok {
+					return Path{}, fmt.Errorf("igbinary: invalid path %q: bad index %q", expr, rest[1:end])
+				}
+				keys = append(keys, rest[1:end])
+			}
+			rest = rest[end+1:]
+		default:
+			return Path{}, fmt.Errorf("igbinary: invalid path %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return NewPath(keys...), nil
+}
+
+// String returns the path in the notation accepted by [ParsePath].
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, s := range p.segments {
+		writePathKey(&b, s.str)
+	}
+	return b.String()
+}
+
+// Get returns the value at p in data using default options. See [Get].
+func (p Path) Get(data []byte) (any, error) {
+	return defaultDecoder.Get(data, p)
+}
+
+// Get returns the value at p in data, decoded with the decoder's options.
+// See the package-level [Get].
+func (d *Decoder) Get(data []byte, p Path) (any, error) {
+	r, err := d.newReader(data)
+	if err != nil {
+		return nil, err
+	}
+	val, err := r.find(p.segments)
+	if err != nil {
+		return nil, err
+	}
+	if d.normalize {
+		val = NormalizeArrays(val, d.normalizeOpts...)
+	}
+	return val, nil
+}
+
+// matches reports whether the array key k is the path segment s.
+func (s pathSegment) matches(k Key) bool {
+	if k.IsInt {
+		return s.isInt && s.int == k.Int
+	}
+	return k.Str == s.str
+}
+
+// find walks path from the current position and decodes the value it leads
+// to, skipping every entry that is not on the way.
+func (r *reader) find(path []pathSegment) (any, error) {
+	for i, seg := range path {
+		start := r.pos
+		code, err := r.readByte()
+		for err == nil && code == TypeSimpleRef {
+			// A PHP reference takes the slot of the value it refers to.
+			code, err = r.readByte()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var n int
+		switch code {
+		case TypeArray8, TypeArray16, TypeArray32:
+			if n, err = r.readSized(code, TypeArray8); err != nil {
+				return nil, err
+			}
+			r.skipped(start, r.nstrings)
+
+		case TypeObject8, TypeObject16, TypeObject32, TypeObjectID8, TypeObjectID16, TypeObjectID32:
+			nstrings := r.nstrings
+			class, err := r.readClassName(code)
+			if err != nil {
+				return nil, err
+			}
+			if seg.str == ClassKey && i == len(path)-1 {
+				return class, nil
+			}
+			if n, err = r.readPropertyCount(); err != nil {
+				return nil, err
+			}
+			r.skipped(start, nstrings)
+
+		case TypeArrayRef8, TypeArrayRef16, TypeArrayRef32, TypeObjectRef8, TypeObjectRef16, TypeObjectRef32:
+			// The target was passed already; follow the rest of the path in
+			// its decoded form.
+			r.pos = start
+			target, err := r.decodeValue()
+			if err != nil {
+				return nil, err
+			}
+			return lookupDecoded(target, path, i)
+
+		default:
+			return nil, pathNotFound(path[:i+1])
+		}
+
+		found := false
+		for j := 0; j < n; j++ {
+			key, err := r.decodeArrayKey()
+			if err != nil {
+				return nil, err
+			}
+			if seg.matches(key) {
+				found = true
+				break
+			}
+			if err := r.skipValue(); err != nil {
+				return nil, err
+			}
+		}
+		if !found {
+			return nil, pathNotFound(path[:i+1])
+		}
+	}
+	return r.decodeValue()
+}
+
+// lookupDecoded follows the segments of path from index from on through an
+// already decoded value.
+func lookupDecoded(v any, path []pathSegment, from int) (any, error) {
+	for i := from; i < len(path); i++ {
+		if ref, ok := v.(*Reference); ok {
+			v = ref.Target
+		}
+		next, ok := lookupEntry(v, path[i].str)
+		if !ok {
+			return nil, pathNotFound(path[:i+1])
+		}
+		v = next
+	}
+	return v, nil
+}
+
+// pathNotFound reports that the last key of path does not exist.
+func pathNotFound(path []pathSegment) error {
+	return fmt.Errorf("%w: %s", ErrPathNotFound, Path{segments: path})
+}
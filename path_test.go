@@ -0,0 +1,110 @@
+package igbinary_test
+
+import (
+	"errors"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// pathPayload has strings registered in skipped subtrees that the target
+// refers back to, and an object referenced after it was skipped.
+func pathPayload(t *testing.T) []byte {
+	t.Helper()
+	customer := map[string]any{igbinary.ClassKey: `App\Customer`, "name": "Ann"}
+	data, err := igbinary.Encode(map[string]any{
+		"a_skipped": map[string]any{"name": "shared", "list": []any{1, 2.5, "x"}},
+		"b_owner":   customer,
+		"user": map[string]any{
+			"id":    int64(42),
+			"role":  "shared",
+			"tags":  []any{"x", "y"},
+			"buyer": customer,
+		},
+	})
+	assertNoError(t, err)
+	return data
+}
+
+func TestGet(t *testing.T) {
+	data := pathPayload(t)
+
+	id, err := igbinary.Get(data, "user", "id")
+	assertNoError(t, err)
+	assertEqualInt64(t, id, 42)
+
+	role, err := igbinary.Get(data, "user", "role")
+	assertNoError(t, err)
+	assertEqualString(t, role, "shared")
+
+	tag, err := igbinary.Get(data, "user", "tags", "1")
+	assertNoError(t, err)
+	assertEqualString(t, tag, "y")
+}
+
+func TestGetThroughObjectReference(t *testing.T) {
+	data := pathPayload(t)
+
+	name, err := igbinary.Get(data, "user", "buyer", "name")
+	assertNoError(t, err)
+	assertEqualString(t, name, "Ann")
+
+	class, err := igbinary.Get(data, "b_owner", igbinary.ClassKey)
+	assertNoError(t, err)
+	assertEqualString(t, class, `App\Customer`)
+}
+
+func TestGetReferencedSubtree(t *testing.T) {
+	data := pathPayload(t)
+
+	buyer, err := igbinary.Get(data, "user", "buyer")
+	assertNoError(t, err)
+	m := buyer.(map[string]any)
+	assertEqualString(t, m["name"], "Ann")
+}
+
+func TestGetNotFound(t *testing.T) {
+	data := pathPayload(t)
+
+	for _, path := range [][]string{{"missing"}, {"user", "id", "deeper"}, {"user", "tags", "5"}} {
+		_, err := igbinary.Get(data, path...)
+		if !errors.Is(err, igbinary.ErrPathNotFound) {
+			t.Errorf("%v: expected ErrPathNotFound, got %v", path, err)
+		}
+	}
+}
+
+func TestGetRoot(t *testing.T) {
+	val, err := igbinary.Get(makePayload(0x06, 0x07))
+	assertNoError(t, err)
+	assertEqualInt64(t, val, 7)
+}
+
+func TestPathCompiled(t *testing.T) {
+	p, err := igbinary.ParsePath(`$.user["tags"][0]`)
+	assertNoError(t, err)
+	if p.String() != "$.user.tags[0]" {
+		t.Errorf("unexpected path string: %s", p)
+	}
+
+	val, err := p.Get(pathPayload(t))
+	assertNoError(t, err)
+	assertEqualString(t, val, "x")
+}
+
+func TestParsePathErrors(t *testing.T) {
+	for _, expr := range []string{"$.", "$[abc]", `$["open`, "$.a b", "x"} {
+		if _, err := igbinary.ParsePath(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestDecoderGetAppliesOptions(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithNormalizeArrays())
+	tags, err := dec.Get(pathPayload(t), igbinary.NewPath("user", "tags"))
+	assertNoError(t, err)
+	if list, ok := tags.([]any); !ok || len(list) != 2 {
+		t.Errorf("expected normalized list, got %#v", tags)
+	}
+}
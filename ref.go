@@ -0,0 +1,148 @@
+package igbinary
+
+import "fmt"
+
+// WithReferences makes [Decoder.Decode] keep back-references and PHP
+// references (&$var) as *[Reference] values, as [Decoder.DecodeValue] does.
+// By default the decoded tree holds the referenced value itself in every
+// slot that shares it: the same map for arrays and objects, and a copy of
+// the value for scalars.
+func WithReferences() Option {
+	return func(d *Decoder) {
+		d.refs = true
+	}
+}
+
+// isCompoundCode reports whether code starts an array, object or
+// serialized object, which take a values table slot of their own.
+func isCompoundCode(code byte) bool {
+	switch code {
+	case TypeArray8, TypeArray16, TypeArray32,
+		TypeObject8, TypeObject16, TypeObject32,
+		TypeObjectID8, TypeObjectID16, TypeObjectID32,
+		TypeObjectSer8, TypeObjectSer16, TypeObjectSer32:
+		return true
+	}
+	return false
+}
+
+// backRefBase returns the 8-bit variant of the back-reference type code
+// group of code, or 0 if code is not a back-reference.
+func backRefBase(code byte) byte {
+	switch code {
+	case TypeArrayRef8, TypeArrayRef16, TypeArrayRef32:
+		return TypeArrayRef8
+	case TypeObjectRef8, TypeObjectRef16, TypeObjectRef32:
+		return TypeObjectRef8
+	}
+	return 0
+}
+
+// decodeSimpleRef decodes the value that follows a TypeSimpleRef code.
+//
+// Like PHP, the reference occupies a values table slot so that later
+// back-references can alias it: an array or object uses the slot it takes
+// anyway, a back-reference the slot it refers to, and any other value a new
+// slot after it. A reference to a reference is the same reference.
+func (r *reader) decodeSimpleRef() (any, error) {
+	if r.pos >= len(r.data) {
+		return nil, newError(ErrUnexpectedEnd, r.pos, "referenced value")
+	}
+	code := r.data[r.pos]
+
+	if base := backRefBase(code); base != 0 {
+		r.pos++
+		id, err := r.readSized(code, base)
+		if err != nil {
+			return nil, err
+		}
+		target, err := r.lookupValue(id)
+		if err != nil {
+			return nil, resumable(err)
+		}
+		return r.shareRef(id, target), nil
+	}
+
+	id := len(r.values)
+	if r.refs && isCompoundCode(code) {
+		// The reference takes the slot before the contents are decoded, so
+		// that they can refer back to it.
+		ref := &Reference{Simple: true}
+		r.nextRef = ref
+		val, err := r.decodeValue()
+		r.nextRef = nil
+		if err != nil {
+			return nil, err
+		}
+		ref.Target = val
+		r.values[id] = ref
+		return ref, nil
+	}
+	val, err := r.decodeValue()
+	if err != nil {
+		return nil, err
+	}
+	if isCompoundCode(code) || code == TypeSimpleRef {
+		return r.shareRef(id, val), nil
+	}
+	r.values = append(r.values, nil)
+	return r.shareRef(id, val), nil
+}
+
+// shareRef makes values table slot id, holding val, a PHP reference and
+// returns what the slot decodes to: the *Reference shared by every slot
+// aliasing it, or val itself without references.
+func (r *reader) shareRef(id int, val any) any {
+	if !r.refs {
+		r.values[id] = val
+		return val
+	}
+	if ref, ok := val.(*Reference); ok && ref.Simple {
+		return ref
+	}
+	ref := &Reference{Simple: true, Target: val}
+	r.values[id] = ref
+	return ref
+}
+
+// skipSimpleRef skips the value that follows a TypeSimpleRef code at start,
+// registering values table slots as decodeSimpleRef does.
+func (r *reader) skipSimpleRef(start int) error {
+	if r.pos >= len(r.data) {
+		return newError(ErrUnexpectedEnd, r.pos, "referenced value")
+	}
+	code := r.data[r.pos]
+
+	if base := backRefBase(code); base != 0 {
+		r.pos++
+		id, err := r.readSized(code, base)
+		if err != nil {
+			return err
+		}
+		if id >= len(r.values) {
+			return newError(ErrValueRefOutOfRange, r.pos,
+				fmt.Sprintf("ID %d, table size %d", id, len(r.values)))
+		}
+		if sv, ok := r.values[id].(*skippedValue); ok {
+			sv.simple = true
+		}
+		return nil
+	}
+
+	id, nstrings := len(r.values), r.nstrings
+	if err := r.skipValue(); err != nil {
+		return err
+	}
+	if isCompoundCode(code) || code == TypeSimpleRef {
+		if sv, ok := r.values[id].(*skippedValue); ok {
+			sv.simple = true
+		}
+		return nil
+	}
+	// The slot of a referenced scalar starts at the TypeSimpleRef code, so
+	// that resolving it decodes the reference again.
+	sv := r.skipped(start, nstrings)
+	sv.simple = true
+	r.skippedEnd(sv)
+	return nil
+}
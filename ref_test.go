@@ -0,0 +1,160 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// scalarRefs is $a = 1; [&$a, &$a]: the second slot is a back-reference to
+// the values table slot the reference took.
+var scalarRefs = makePayload(0x14, 0x02, 0x06, 0x00, 0x25, 0x06, 0x01, 0x06, 0x01, 0x01, 0x01)
+
+// arrayRefs is $b = [1]; [&$b, &$b].
+var arrayRefs = makePayload(0x14, 0x02,
+	0x06, 0x00, 0x25, 0x14, 0x01, 0x06, 0x00, 0x06, 0x01,
+	0x06, 0x01, 0x01, 0x01,
+)
+
+func TestDecodeSimpleRefShared(t *testing.T) {
+	val, err := igbinary.Decode(scalarRefs)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	assertEqualInt64(t, m["0"], 1)
+	assertEqualInt64(t, m["1"], 1)
+
+	val, err = igbinary.Decode(arrayRefs)
+	assertNoError(t, err)
+	m = val.(map[string]any)
+	first, second := m["0"].(map[string]any), m["1"].(map[string]any)
+	first["x"] = true
+	if second["x"] != true {
+		t.Error("expected both slots to share one map")
+	}
+}
+
+// selfRef is $a = []; $a[0] = &$a; &$a: the array holds a reference to
+// itself.
+var selfRef = makePayload(0x25, 0x14, 0x01, 0x06, 0x00, 0x25, 0x01, 0x00)
+
+func TestDecodeSelfReference(t *testing.T) {
+	val, err := igbinary.NewDecoder(igbinary.WithReferences()).Decode(selfRef)
+	assertNoError(t, err)
+	outer, ok := val.(*igbinary.Reference)
+	if !ok || !outer.Simple {
+		t.Fatalf("expected a simple *Reference, got %#v", val)
+	}
+	if inner := outer.Target.(map[string]any)["0"]; inner != outer {
+		t.Errorf("expected the array to hold the outer *Reference, got %#v", inner)
+	}
+
+	for name, decode := range map[string]func([]byte) (igbinary.Value, error){
+		"DecodeValue": igbinary.DecodeValue,
+		"DecodeLazy":  igbinary.DecodeLazy,
+	} {
+		v, err := decode(selfRef)
+		assertNoError(t, err)
+		if v.Kind() != igbinary.Ref || v.Get("0").Interface() != v.Interface() {
+			t.Errorf("%s: expected the array to hold the outer *Reference", name)
+		}
+	}
+
+	v, err := igbinary.DecodeValue(selfRef)
+	assertNoError(t, err)
+	out, err := igbinary.Encode(v)
+	assertNoError(t, err)
+	if !bytes.Equal(out, selfRef) {
+		t.Errorf("round trip mismatch:\n got %x\nwant %x", out, selfRef)
+	}
+}
+
+func TestDecodeWithReferences(t *testing.T) {
+	for name, data := range map[string][]byte{"scalar": scalarRefs, "array": arrayRefs} {
+		val, err := igbinary.NewDecoder(igbinary.WithReferences()).Decode(data)
+		assertNoError(t, err)
+		m := val.(map[string]any)
+		first, ok := m["0"].(*igbinary.Reference)
+		if !ok || !first.Simple {
+			t.Fatalf("%s: expected a simple *Reference, got %#v", name, m["0"])
+		}
+		if m["1"] != first {
+			t.Errorf("%s: expected both slots to hold the same *Reference", name)
+		}
+	}
+}
+
+func TestDecodeValueSimpleRef(t *testing.T) {
+	v, err := igbinary.DecodeValue(scalarRefs)
+	assertNoError(t, err)
+	if k := v.Index(0).Kind(); k != igbinary.Ref {
+		t.Errorf("expected kind ref, got %v", k)
+	}
+	assertEqualInt64(t, v.Index(1).Int(), 1)
+	if v.Index(0).Interface() != v.Index(1).Interface() {
+		t.Error("expected both slots to hold the same *Reference")
+	}
+}
+
+func TestDecodeLazySimpleRef(t *testing.T) {
+	for name, data := range map[string][]byte{"scalar": scalarRefs, "array": arrayRefs} {
+		v, err := igbinary.DecodeLazy(data)
+		assertNoError(t, err)
+		first, second := v.Get("0").Interface(), v.Get("1").Interface()
+		ref, ok := first.(*igbinary.Reference)
+		if !ok || !ref.Simple || first != second {
+			t.Errorf("%s: expected one shared simple *Reference, got %#v and %#v", name, first, second)
+		}
+	}
+
+	v, err := igbinary.DecodeLazy(arrayRefs)
+	assertNoError(t, err)
+	assertEqualInt64(t, v.Get("1").Get("0").Int(), 1)
+}
+
+func TestGetThroughSimpleRef(t *testing.T) {
+	val, err := igbinary.Get(scalarRefs, "1")
+	assertNoError(t, err)
+	assertEqualInt64(t, val, 1)
+
+	for _, key := range []string{"0", "1"} {
+		val, err = igbinary.Get(arrayRefs, key, "0")
+		assertNoError(t, err)
+		assertEqualInt64(t, val, 1)
+	}
+}
+
+func TestEncodeSimpleRefRoundTrip(t *testing.T) {
+	for name, data := range map[string][]byte{"scalar": scalarRefs, "array": arrayRefs} {
+		v, err := igbinary.DecodeValue(data)
+		assertNoError(t, err)
+		out, err := igbinary.Encode(v)
+		assertNoError(t, err)
+		if !bytes.Equal(out, data) {
+			t.Errorf("%s: round trip mismatch:\n got %x\nwant %x", name, out, data)
+		}
+	}
+}
+
+func TestTokenizerSimpleRef(t *testing.T) {
+	tok := igbinary.NewTokenizer(scalarRefs)
+	var refs []igbinary.Token
+	for {
+		tk, err := tok.Next()
+		if err == io.EOF {
+			break
+		}
+		assertNoError(t, err)
+		if tk.Kind == igbinary.TokenScalar || tk.Kind == igbinary.TokenRef {
+			refs = append(refs, tk)
+		}
+	}
+	if len(refs) != 2 || !refs[0].Reference || refs[0].ID != 1 || refs[1].ID != 1 {
+		t.Errorf("unexpected tokens %+v", refs)
+	}
+
+	val, err := igbinary.NewTokenizer(scalarRefs).Decode()
+	assertNoError(t, err)
+	assertEqualInt64(t, val.(map[string]any)["1"], 1)
+}
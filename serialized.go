@@ -0,0 +1,429 @@
+package igbinary
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// WithDecodeSerialized makes the decoder look inside the data of objects
+// stored through PHP's Serializable interface. Most serialize() methods
+// return the output of PHP's serialize() or igbinary_serialize(); data in
+// either format is decoded like the rest of the payload and stored under
+// [SerializedValueKey], next to the raw data under [SerializedDataKey]:
+//
+//	dec := igbinary.NewDecoder(igbinary.WithDecodeSerialized())
+//	v, err := dec.DecodeValue(data)
+//	items := v.Get("cart").Unserialized().Get("items")
+//
+// Data in any other format is left raw. [Encode] always writes the raw
+// data, so decoded payloads round-trip unchanged.
+func WithDecodeSerialized() Option {
+	return func(d *Decoder) {
+		d.decodeSerialized = true
+	}
+}
+
+// decodeSerializedData decodes the data of a serialized object if it is in
+// PHP serialize() or igbinary format, and reports whether it was. Only
+// limit violations are returned as errors; data that does not parse is
+// simply not decoded.
+func (r *reader) decodeSerializedData(raw []byte) (any, bool, error) {
+	// Serialized objects can nest without arrays in between, so they count
+	// towards the depth limit themselves.
+	if err := r.enter(0); err != nil {
+		return nil, false, err
+	}
+	defer r.leave()
+
+	var val any
+	var err error
+	if bytes.HasPrefix(raw, []byte{0x00, 0x00, 0x00, FormatVersion}) {
+		val, err = r.decodeNested(raw)
+	} else {
+		u := unserializer{r: r, data: raw}
+		val, err = u.decode()
+	}
+	if err != nil {
+		if isLimitError(err) {
+			return nil, false, err
+		}
+		return nil, false, nil
+	}
+	return val, true, nil
+}
+
+// decodeNested decodes an igbinary payload embedded in the one r reads. It
+// has string and values tables of its own, but shares the limits.
+func (r *reader) decodeNested(data []byte) (any, error) {
+	sub := *r
+	sub.data, sub.pos = data, 4
+	sub.strings, sub.nstrings, sub.stringBytes, sub.values, sub.nextRef = nil, 0, nil, nil, nil
+	sub.large, sub.largeByID, sub.path = largeStrings{}, nil, nil
+	sub.partial, sub.errs = false, nil
+
+	val, err := sub.decodeValue()
+	if err == nil && sub.pos != len(data) {
+		err = newError(ErrTrailingData, sub.pos, "")
+	}
+	r.elements, r.allocated = sub.elements, sub.allocated
+	return val, err
+}
+
+// isLimitError reports whether err is a violation of one of the decoder's
+// limits.
+func isLimitError(err error) bool {
+	return errors.Is(err, ErrMaxDepthExceeded) || errors.Is(err, ErrMaxElementsExceeded) ||
+		errors.Is(err, ErrMaxStringLengthExceeded) || errors.Is(err, ErrMaxTotalBytesExceeded)
+}
+
+// unserializer parses the output of PHP's serialize() into the same values
+// r builds for igbinary.
+type unserializer struct {
+	r    *reader
+	data []byte
+	pos  int
+	vars []any // values by PHP's reference number, less one
+}
+
+// decode parses data holding exactly one value.
+func (u *unserializer) decode() (any, error) {
+	val, err := u.value()
+	if err != nil {
+		return nil, err
+	}
+	if u.pos != len(u.data) {
+		return nil, u.syntaxError("trailing data")
+	}
+	return val, nil
+}
+
+func (u *unserializer) syntaxError(detail string) error {
+	return newError(ErrInvalidSerializedData, u.pos, "serialize() data: "+detail)
+}
+
+// value parses one value. Every value except an R: reference takes a
+// reference number, in the order the values start, as in PHP.
+func (u *unserializer) value() (any, error) {
+	if u.pos+1 >= len(u.data) {
+		return nil, u.syntaxError("unexpected end")
+	}
+	t := u.data[u.pos]
+	slot := -1
+	if t != 'R' {
+		slot = len(u.vars)
+		u.vars = append(u.vars, nil)
+	}
+	if t == 'N' {
+		u.pos++
+		return nil, u.expect(";")
+	}
+	if u.data[u.pos+1] != ':' {
+		return nil, u.syntaxError(fmt.Sprintf("unknown type %q", t))
+	}
+	u.pos += 2
+
+	var val any
+	var err error
+	switch t {
+	case 'b':
+		var n int64
+		if n, err = u.int(';'); err == nil && n != 0 && n != 1 {
+			err = u.syntaxError("bad bool")
+		}
+		val = n == 1
+	case 'i':
+		val, err = u.int(';')
+	case 'd':
+		val, err = u.float()
+	case 's':
+		var b []byte
+		if b, err = u.string(); err == nil {
+			val = u.stringValue(b)
+		}
+	case 'a':
+		val, err = u.array(slot)
+	case 'O':
+		val, err = u.object(slot)
+	case 'C':
+		val, err = u.custom()
+	case 'E':
+		val, err = u.enum()
+	case 'r', 'R':
+		val, err = u.reference(t)
+	default:
+		err = u.syntaxError(fmt.Sprintf("unknown type %q", t))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if slot >= 0 {
+		u.vars[slot] = val
+	}
+	return val, nil
+}
+
+// expect consumes s.
+func (u *unserializer) expect(s string) error {
+	if !bytes.HasPrefix(u.data[u.pos:], []byte(s)) {
+		return u.syntaxError(fmt.Sprintf("expected %q", s))
+	}
+	u.pos += len(s)
+	return nil
+}
+
+// token returns the bytes up to the terminator term and consumes both.
+func (u *unserializer) token(term byte) ([]byte, error) {
+	end := bytes.IndexByte(u.data[u.pos:], term)
+	if end < 0 {
+		return nil, u.syntaxError(fmt.Sprintf("expected %q", term))
+	}
+	tok := u.data[u.pos : u.pos+end]
+	u.pos += end + 1
+	return tok, nil
+}
+
+func (u *unserializer) int(term byte) (int64, error) {
+	tok, err := u.token(term)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(string(tok), 10, 64)
+	if err != nil {
+		return 0, u.syntaxError(fmt.Sprintf("bad integer %q", tok))
+	}
+	return i, nil
+}
+
+// length reads a non-negative count or byte length.
+func (u *unserializer) length(term byte) (int, error) {
+	n, err := u.int(term)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > int64(len(u.data)) {
+		return 0, u.syntaxError(fmt.Sprintf("bad length %d", n))
+	}
+	return int(n), nil
+}
+
+// float reads a double, written by PHP in decimal or as INF, -INF or NAN.
+func (u *unserializer) float() (float64, error) {
+	tok, err := u.token(';')
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(string(tok), 64)
+	if err != nil {
+		return 0, u.syntaxError(fmt.Sprintf("bad double %q", tok))
+	}
+	return f, nil
+}
+
+// string reads the rest of an s: value, N:"...";.
+func (u *unserializer) string() ([]byte, error) {
+	b, err := u.quoted()
+	if err != nil {
+		return nil, err
+	}
+	return b, u.expect(";")
+}
+
+// quoted reads a length-prefixed quoted string, N:"...".
+func (u *unserializer) quoted() ([]byte, error) {
+	n, err := u.length(':')
+	if err != nil {
+		return nil, err
+	}
+	if err := u.r.checkString(n); err != nil {
+		return nil, err
+	}
+	if err := u.r.alloc(int64(n)); err != nil {
+		return nil, err
+	}
+	if err := u.expect(`"`); err != nil {
+		return nil, err
+	}
+	if u.pos+n > len(u.data) {
+		return nil, u.syntaxError("unexpected end")
+	}
+	b := u.data[u.pos : u.pos+n]
+	u.pos += n
+	return b, u.expect(`"`)
+}
+
+// stringValue returns a string value as the reader would decode it.
+func (u *unserializer) stringValue(b []byte) any {
+	if u.r.byteStrings {
+		return b
+	}
+	return u.r.makeString(b)
+}
+
+// key reads an array key or property name.
+func (u *unserializer) key() (Key, error) {
+	if u.pos+1 >= len(u.data) || u.data[u.pos+1] != ':' {
+		return Key{}, u.syntaxError("bad key")
+	}
+	t := u.data[u.pos]
+	u.pos += 2
+	switch t {
+	case 'i':
+		i, err := u.int(';')
+		return IntKey(i), err
+	case 's':
+		b, err := u.string()
+		if err != nil {
+			return Key{}, err
+		}
+		return StringKey(u.r.makeString(b)), nil
+	}
+	return Key{}, u.syntaxError(fmt.Sprintf("bad key type %q", t))
+}
+
+// entries reads n key/value pairs in braces into m.
+func (u *unserializer) entries(m any, n int) error {
+	if err := u.expect("{"); err != nil {
+		return err
+	}
+	if err := u.r.enter(n); err != nil {
+		return err
+	}
+	defer u.r.leave()
+
+	for i := 0; i < n; i++ {
+		key, err := u.key()
+		if err != nil {
+			return err
+		}
+		val, err := u.value()
+		if err != nil {
+			return err
+		}
+		setEntry(m, key, val)
+	}
+	return u.expect("}")
+}
+
+// newArray creates a container for n entries, trusting n no further than
+// the rest of the data can fill.
+func (u *unserializer) newArray(n int) any {
+	return u.r.newArray(min(n, (len(u.data)-u.pos)/4))
+}
+
+// array reads the rest of an a: value, N:{...}. It is registered in slot
+// before its entries, which may refer back to it.
+func (u *unserializer) array(slot int) (any, error) {
+	n, err := u.length(':')
+	if err != nil {
+		return nil, err
+	}
+	m := u.newArray(n)
+	u.vars[slot] = m
+	if err := u.entries(m, n); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// object reads the rest of an O: value, N:"Class":N:{...}.
+func (u *unserializer) object(slot int) (any, error) {
+	class, err := u.quoted()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.expect(":"); err != nil {
+		return nil, err
+	}
+	n, err := u.length(':')
+	if err != nil {
+		return nil, err
+	}
+	className := u.r.makeString(class)
+	m := u.r.newObject(className, min(n, (len(u.data)-u.pos)/4))
+	u.vars[slot] = m
+	if err := u.entries(m, n); err != nil {
+		return nil, err
+	}
+	return u.r.instantiate(className, m)
+}
+
+// custom reads the rest of a C: value, N:"Class":N:{data}: an object that
+// implements Serializable, nested in serialize() data.
+func (u *unserializer) custom() (any, error) {
+	class, err := u.quoted()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.expect(":"); err != nil {
+		return nil, err
+	}
+	n, err := u.length(':')
+	if err != nil {
+		return nil, err
+	}
+	if err := u.expect("{"); err != nil {
+		return nil, err
+	}
+	if u.pos+n > len(u.data) {
+		return nil, u.syntaxError("unexpected end")
+	}
+	raw := u.data[u.pos : u.pos+n]
+	u.pos += n
+	if err := u.expect("}"); err != nil {
+		return nil, err
+	}
+
+	className := u.r.makeString(class)
+	val, ok, err := u.r.decodeSerializedData(raw)
+	if err != nil {
+		return nil, err
+	}
+	m := u.r.newSerializedObject(className, raw, val, ok)
+	return u.r.instantiate(className, m)
+}
+
+// enum reads the rest of an E: value, N:"Class:Case";, as an object of the
+// enum's class with the case under "name", like PHP's enum property.
+func (u *unserializer) enum() (any, error) {
+	b, err := u.string()
+	if err != nil {
+		return nil, err
+	}
+	class, name, ok := bytes.Cut(b, []byte(":"))
+	if !ok {
+		return nil, u.syntaxError(fmt.Sprintf("bad enum %q", b))
+	}
+	className := u.r.makeString(class)
+	m := u.r.newObject(className, 1)
+	setEntry(m, StringKey("name"), u.r.makeString(name))
+	return u.r.instantiate(className, m)
+}
+
+// reference reads the rest of an r: (object) or R: (PHP reference) value.
+// The referenced value is shared, or returned as a *Reference when the
+// reader keeps references.
+func (u *unserializer) reference(t byte) (any, error) {
+	n, err := u.int(';')
+	if err != nil {
+		return nil, err
+	}
+	// The r: value itself has taken a slot already.
+	if n < 1 || n > int64(len(u.vars)) || t == 'r' && n == int64(len(u.vars)) {
+		return nil, u.syntaxError(fmt.Sprintf("reference %d out of range", n))
+	}
+	target := u.vars[n-1]
+	if !u.r.refs {
+		return target, nil
+	}
+	if ref, ok := target.(*Reference); ok {
+		return ref, nil
+	}
+	ref := &Reference{Object: t == 'r', Simple: t == 'R', Target: target}
+	if t == 'R' {
+		// Later references to the same variable share the *Reference.
+		u.vars[n-1] = ref
+	}
+	return ref, nil
+}
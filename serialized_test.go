@@ -0,0 +1,169 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// serializedObject returns a payload holding an object of class that
+// implements Serializable, stored with data.
+func serializedObject(class, data string) []byte {
+	b := []byte{0x1D, byte(len(class))}
+	b = append(b, class...)
+	b = append(b, 0x11, byte(len(data)))
+	b = append(b, data...)
+	return makePayload(b...)
+}
+
+func decodeSerialized(t *testing.T, data string, opts ...igbinary.Option) map[string]any {
+	t.Helper()
+	opts = append(opts, igbinary.WithDecodeSerialized())
+	val, err := igbinary.NewDecoder(opts...).Decode(serializedObject("Cart", data))
+	assertNoError(t, err)
+	m, ok := val.(map[string]any)
+	if !ok {
+		t.Fatalf("expected map[string]any, got %T", val)
+	}
+	if m[igbinary.SerializedDataKey] != data {
+		t.Errorf("expected raw data to be kept, got %v", m[igbinary.SerializedDataKey])
+	}
+	return m
+}
+
+func TestDecodeSerializedPHPFormat(t *testing.T) {
+	data := `a:2:{i:0;s:1:"x";s:4:"user";O:8:"stdClass":2:{s:2:"id";i:7;s:4:"tags";a:0:{}}}`
+	m := decodeSerialized(t, data)
+	inner, ok := m[igbinary.SerializedValueKey].(map[string]any)
+	if !ok {
+		t.Fatalf("expected decoded data, got %#v", m[igbinary.SerializedValueKey])
+	}
+	assertEqualString(t, inner["0"], "x")
+	user := inner["user"].(map[string]any)
+	assertEqualString(t, user[igbinary.ClassKey], "stdClass")
+	assertEqualInt64(t, user["id"], 7)
+
+	plain, err := igbinary.Decode(serializedObject("Cart", data))
+	assertNoError(t, err)
+	if _, ok := plain.(map[string]any)[igbinary.SerializedValueKey]; ok {
+		t.Error("expected data to stay raw without WithDecodeSerialized")
+	}
+}
+
+func TestDecodeSerializedScalars(t *testing.T) {
+	tests := []struct {
+		data string
+		want any
+	}{
+		{`N;`, nil},
+		{`b:1;`, true},
+		{`b:0;`, false},
+		{`i:-42;`, int64(-42)},
+		{`d:0.5;`, 0.5},
+		{`d:-INF;`, math.Inf(-1)},
+		{`s:5:"a"b;c";`, `a"b;c`},
+	}
+	for _, tt := range tests {
+		m := decodeSerialized(t, tt.data)
+		got, ok := m[igbinary.SerializedValueKey]
+		if !ok || got != tt.want {
+			t.Errorf("%s: expected %#v, got %#v", tt.data, tt.want, got)
+		}
+	}
+
+	m := decodeSerialized(t, `d:NAN;`)
+	if f, _ := m[igbinary.SerializedValueKey].(float64); !math.IsNaN(f) {
+		t.Errorf("expected NaN, got %#v", m[igbinary.SerializedValueKey])
+	}
+}
+
+func TestDecodeSerializedNestedIgbinary(t *testing.T) {
+	m := decodeSerialized(t, string(makePayload(0x14, 0x01, 0x06, 0x00, 0x06, 0x05)))
+	inner := m[igbinary.SerializedValueKey].(map[string]any)
+	assertEqualInt64(t, inner["0"], 5)
+}
+
+func TestDecodeSerializedUnknownFormat(t *testing.T) {
+	for _, data := range []string{
+		`x:i:0;a:0:{};m:a:0:{}`, // ArrayObject's own format
+		`a:1:{i:0;i:1;`,         // truncated
+		`i:1;i:2;`,              // trailing data
+		`{"json":true}`,
+	} {
+		m := decodeSerialized(t, data)
+		if v, ok := m[igbinary.SerializedValueKey]; ok {
+			t.Errorf("%s: expected no decoded data, got %#v", data, v)
+		}
+	}
+}
+
+func TestDecodeSerializedReferences(t *testing.T) {
+	// [$o, $o] and [$x, &$x]
+	objects := `a:2:{i:0;O:8:"stdClass":0:{}i:1;r:2;}`
+	vars := `a:2:{i:0;i:5;i:1;R:2;}`
+
+	inner := decodeSerialized(t, objects)[igbinary.SerializedValueKey].(map[string]any)
+	first := inner["0"].(map[string]any)
+	first["x"] = 1
+	if inner["1"].(map[string]any)["x"] != 1 {
+		t.Error("expected both slots to share the object")
+	}
+	inner = decodeSerialized(t, vars)[igbinary.SerializedValueKey].(map[string]any)
+	assertEqualInt64(t, inner["1"], 5)
+
+	inner = decodeSerialized(t, objects, igbinary.WithReferences())[igbinary.SerializedValueKey].(map[string]any)
+	if ref, ok := inner["1"].(*igbinary.Reference); !ok || !ref.Object {
+		t.Errorf("expected an object *Reference, got %#v", inner["1"])
+	}
+	inner = decodeSerialized(t, vars, igbinary.WithReferences())[igbinary.SerializedValueKey].(map[string]any)
+	if ref, ok := inner["1"].(*igbinary.Reference); !ok || !ref.Simple {
+		t.Errorf("expected a simple *Reference, got %#v", inner["1"])
+	}
+}
+
+func TestDecodeSerializedNestedCustom(t *testing.T) {
+	inner := decodeSerialized(t, `C:4:"Item":5:{i:42;}`)[igbinary.SerializedValueKey].(map[string]any)
+	assertEqualString(t, inner[igbinary.ClassKey], "Item")
+	assertEqualString(t, inner[igbinary.SerializedDataKey], "i:42;")
+	assertEqualInt64(t, inner[igbinary.SerializedValueKey], 42)
+}
+
+func TestDecodeSerializedEnum(t *testing.T) {
+	inner := decodeSerialized(t, `E:11:"Suit:Hearts";`)[igbinary.SerializedValueKey].(map[string]any)
+	assertEqualString(t, inner[igbinary.ClassKey], "Suit")
+	assertEqualString(t, inner["name"], "Hearts")
+}
+
+func TestDecodeSerializedLimits(t *testing.T) {
+	data := serializedObject("Cart", `a:1:{i:0;a:1:{i:0;a:1:{i:0;a:0:{}}}}`)
+	dec := igbinary.NewDecoder(igbinary.WithDecodeSerialized(), igbinary.WithMaxDepth(3))
+	_, err := dec.Decode(data)
+	if !errors.Is(err, igbinary.ErrMaxDepthExceeded) {
+		t.Errorf("expected ErrMaxDepthExceeded, got %v", err)
+	}
+
+	dec = igbinary.NewDecoder(igbinary.WithDecodeSerialized(), igbinary.WithMaxStringLength(3))
+	_, err = dec.Decode(serializedObject("Cart", `s:4:"long";`))
+	if !errors.Is(err, igbinary.ErrMaxStringLengthExceeded) {
+		t.Errorf("expected ErrMaxStringLengthExceeded, got %v", err)
+	}
+}
+
+func TestDecodeSerializedValue(t *testing.T) {
+	data := serializedObject("Cart", `a:1:{s:5:"items";a:1:{i:0;s:3:"sku";}}`)
+	v, err := igbinary.NewDecoder(igbinary.WithDecodeSerialized()).DecodeValue(data)
+	assertNoError(t, err)
+	if k := v.Kind(); k != igbinary.Serialized {
+		t.Fatalf("expected kind serialized, got %v", k)
+	}
+	assertEqualString(t, v.Unserialized().Get("items").Index(0).Str(), "sku")
+
+	out, err := igbinary.Encode(v)
+	assertNoError(t, err)
+	if !bytes.Equal(out, data) {
+		t.Errorf("round trip mismatch:\n got %x\nwant %x", out, data)
+	}
+}
@@ -0,0 +1,267 @@
+package igbinary
+
+import "fmt"
+
+// skippedValue stands in the values table for an array or object that was
+// skipped instead of decoded. It records enough state to decode the value
+// later, should a back-reference need it.
+type skippedValue struct {
+	pos      int  // offset of the value's type code
+	nstrings int  // size of the string table when the value started
+	id       int  // the value's own values table ID
+	simple   bool // the slot is a PHP reference (&$var)
+
+	// State after the value, set once it has been skipped completely.
+	end, nstringsEnd, idEnd int
+}
+
+// skipped registers a skipped compound value that started at pos with the
+// string table at nstrings entries.
+func (r *reader) skipped(pos, nstrings int) *skippedValue {
+	s := &skippedValue{pos: pos, nstrings: nstrings, id: len(r.values)}
+	r.values = append(r.values, s)
+	return s
+}
+
+// skippedEnd records the state after s.
+func (r *reader) skippedEnd(s *skippedValue) {
+	s.end, s.nstringsEnd, s.idEnd = r.pos, r.nstrings, len(r.values)
+}
+
+// resolveSkipped decodes a skipped value by re-reading it with the string
+// and values tables it started with, and caches the result.
+func (r *reader) resolveSkipped(s *skippedValue) (any, error) {
+	fork := *r
+	fork.pos = s.pos
+	fork.nstrings = s.nstrings
+	fork.strings = r.strings[:len(r.strings):len(r.strings)]
+	fork.stringBytes = r.stringBytes[:len(r.stringBytes):len(r.stringBytes)]
+	fork.values = r.values[:s.id:s.id]
+	var ref *Reference
+	if s.simple && r.refs && isCompoundCode(r.data[s.pos]) {
+		// As in decodeSimpleRef, the contents may refer back to the reference.
+		ref = &Reference{Simple: true}
+		fork.nextRef = ref
+	}
+	val, err := fork.decodeValue()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ref != nil:
+		ref.Target = val
+		val = ref
+	case s.simple && r.refs:
+		if ref, ok := val.(*Reference); !ok || !ref.Simple {
+			val = &Reference{Simple: true, Target: val}
+		}
+	}
+	r.values[s.id] = val
+	return val, nil
+}
+
+// readSized reads a big-endian length or ID of 1, 2 or 4 bytes, selected by
+// the offset of code from the 8-bit variant base of its type code group.
+func (r *reader) readSized(code, base byte) (int, error) {
+	switch code - base {
+	case 0:
+		v, err := r.readUint8()
+		return int(v), err
+	case 1:
+		v, err := r.readUint16()
+		return int(v), err
+	default:
+		v, err := r.readUint32()
+		return int(v), err
+	}
+}
+
+// skipValue advances past one value without building it. New strings are
+// still registered and compound values still take a values table slot, so
+// that back-references later in the payload resolve as if the value had
+// been decoded.
+func (r *reader) skipValue() error {
+	start := r.pos
+	code, err := r.readByte()
+	if err != nil {
+		return err
+	}
+	if err := r.skipCode(code, start); err != nil {
+		return withCode(err, code, start)
+	}
+	return nil
+}
+
+// skipCode skips the value introduced by the type code just read, which
+// started at start.
+func (r *reader) skipCode(code byte, start int) error {
+	var err error
+
+	switch code {
+	case TypeNil, TypeBoolFalse, TypeBoolTrue, TypeStringEmpty:
+		return nil
+	case TypeSimpleRef:
+		return r.skipSimpleRef(start)
+	case TypePosInt8, TypeNegInt8:
+		_, err = r.readBytes(1)
+	case TypePosInt16, TypeNegInt16:
+		_, err = r.readBytes(2)
+	case TypePosInt32, TypeNegInt32:
+		_, err = r.readBytes(4)
+	case TypePosInt64, TypeNegInt64, TypeDouble:
+		_, err = r.readBytes(8)
+
+	case TypeStringID8, TypeStringID16, TypeStringID32:
+		id, err := r.readSized(code, TypeStringID8)
+		if err != nil {
+			return err
+		}
+		_, err = r.lookupString(id)
+		return err
+
+	case TypeArrayRef8, TypeArrayRef16, TypeArrayRef32, TypeObjectRef8, TypeObjectRef16, TypeObjectRef32:
+		base := TypeArrayRef8
+		if code >= TypeObjectRef8 {
+			base = TypeObjectRef8
+		}
+		id, err := r.readSized(code, base)
+		if err != nil {
+			return err
+		}
+		if id >= len(r.values) {
+			return newError(ErrValueRefOutOfRange, r.pos,
+				fmt.Sprintf("ID %d, table size %d", id, len(r.values)))
+		}
+		return nil
+
+	case TypeString8, TypeString16, TypeString32:
+		n, err := r.readSized(code, TypeString8)
+		if err != nil {
+			return err
+		}
+		_, err = r.readAndRegisterString(n)
+		return err
+
+	case TypeArray8, TypeArray16, TypeArray32:
+		n, err := r.readSized(code, TypeArray8)
+		if err != nil {
+			return err
+		}
+		sv := r.skipped(start, r.nstrings)
+		if err := r.skipEntries(n, ""); err != nil {
+			return err
+		}
+		r.skippedEnd(sv)
+		return nil
+
+	case TypeObject8, TypeObject16, TypeObject32, TypeObjectID8, TypeObjectID16, TypeObjectID32:
+		nstrings := r.nstrings
+		class, err := r.readClassName(code)
+		if err != nil {
+			return err
+		}
+		n, err := r.readPropertyCount()
+		if err != nil {
+			return err
+		}
+		sv := r.skipped(start, nstrings)
+		if err := r.skipEntries(n, class); err != nil {
+			return err
+		}
+		r.skippedEnd(sv)
+		return nil
+
+	case TypeObjectSer8, TypeObjectSer16, TypeObjectSer32:
+		nstrings := r.nstrings
+		n, err := r.readSized(code, TypeObjectSer8)
+		if err != nil {
+			return err
+		}
+		if _, err := r.readAndRegisterString(n); err != nil {
+			return err
+		}
+		dataCode, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		if dataCode != TypeString8 && dataCode != TypeString16 && dataCode != TypeString32 {
+			return newError(ErrInvalidSerializedData, r.pos-1,
+				fmt.Sprintf("expected string type code, got 0x%02x", dataCode))
+		}
+		if n, err = r.readSized(dataCode, TypeString8); err != nil {
+			return err
+		}
+		if err := r.checkString(n); err != nil {
+			return err
+		}
+		if err := r.alloc(int64(n)); err != nil {
+			return err
+		}
+		if _, err := r.readBytes(n); err != nil {
+			return err
+		}
+		r.skippedEnd(r.skipped(start, nstrings))
+		return nil
+
+	default:
+		return newError(ErrUnknownType, r.pos-1, fmt.Sprintf("0x%02x", code))
+	}
+	return err
+}
+
+// skipEntries skips n key/value pairs of an array, or of an object of class
+// class.
+func (r *reader) skipEntries(n int, class string) error {
+	if err := r.enter(n); err != nil {
+		return err
+	}
+	defer r.leave()
+
+	for i := 0; i < n; i++ {
+		key, err := r.decodeArrayKey()
+		if err != nil {
+			return withDetail(err, fmt.Sprintf("key of entry %d", i))
+		}
+		if err := r.skipValue(); err != nil {
+			return withPathElem(err, PathElem{Key: key, Index: i, Class: class})
+		}
+	}
+	return nil
+}
+
+// readClassName reads the class name of an object with an inline or
+// back-referenced name.
+func (r *reader) readClassName(code byte) (string, error) {
+	switch code {
+	case TypeObject8, TypeObject16, TypeObject32:
+		n, err := r.readSized(code, TypeObject8)
+		if err != nil {
+			return "", err
+		}
+		return r.readAndRegisterString(n)
+	default:
+		id, err := r.readSized(code, TypeObjectID8)
+		if err != nil {
+			return "", err
+		}
+		class, err := r.lookupString(id)
+		if err != nil {
+			return "", withDetail(err, "object class ID")
+		}
+		return class, nil
+	}
+}
+
+// readPropertyCount reads the array header that introduces the properties
+// of an object.
+func (r *reader) readPropertyCount() (int, error) {
+	code, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if code != TypeArray8 && code != TypeArray16 && code != TypeArray32 {
+		return 0, newError(ErrInvalidObjectProperties, r.pos-1,
+			fmt.Sprintf("expected array type code, got 0x%02x", code))
+	}
+	return r.readSized(code, TypeArray8)
+}
@@ -0,0 +1,244 @@
+package igbinary
+
+import "strconv"
+
+// WithSPL decodes PHP's SPL data structures into the data they hold instead
+// of their internal layout:
+//
+//   - ArrayObject, ArrayIterator and RecursiveArrayIterator -> the wrapped
+//     array or object
+//   - SplObjectStorage -> []any of arrays holding each object under "obj"
+//     and its data under "inf", as var_dump() shows them
+//   - SplFixedArray -> []any
+//   - SplDoublyLinkedList, SplQueue and SplStack -> []any, in the order the
+//     elements were pushed
+//
+// Both the Serializable layout written before PHP 7.4 and the __serialize()
+// layout of later versions are understood. Objects whose data does not
+// match the expected layout are left as they are. Classes registered with
+// [Decoder.RegisterClass] take precedence.
+func WithSPL() Option {
+	return func(d *Decoder) {
+		d.spl = true
+	}
+}
+
+// Kinds of SPL classes decodeSPL handles.
+const (
+	splArrayObject = iota + 1
+	splObjectStorage
+	splFixedArray
+	splDoublyLinkedList
+)
+
+var splClasses = map[string]int{
+	"arrayobject":            splArrayObject,
+	"arrayiterator":          splArrayObject,
+	"recursivearrayiterator": splArrayObject,
+	"splobjectstorage":       splObjectStorage,
+	"splfixedarray":          splFixedArray,
+	"spldoublylinkedlist":    splDoublyLinkedList,
+	"splqueue":               splDoublyLinkedList,
+	"splstack":               splDoublyLinkedList,
+}
+
+// decodeSPL converts a decoded object of an SPL class into the data it
+// holds, and reports whether it did. Only limit violations are returned as
+// errors.
+func (r *reader) decodeSPL(className string, obj any) (any, bool, error) {
+	kind := splClasses[normalizeClass(className)]
+	if kind == 0 {
+		return nil, false, nil
+	}
+	if raw, ok := entryString(obj, SerializedDataKey); ok {
+		return r.decodeSPLSerializable(kind, raw)
+	}
+
+	var val any
+	var ok bool
+	switch kind {
+	case splArrayObject:
+		// [flags, storage, members, iterator class]
+		val, ok = lookupEntry(obj, "1")
+	case splObjectStorage:
+		// [[object, data, object, data, ...], members]
+		storage, _ := lookupEntry(obj, "0")
+		val, ok = r.objectStoragePairs(storage)
+	case splFixedArray:
+		// The elements under integer keys, then any members.
+		val, ok = fixedArrayElements(obj)
+	case splDoublyLinkedList:
+		// [flags, [elements...], members]
+		elems, _ := lookupEntry(obj, "1")
+		val, ok = listElements(elems)
+	}
+	return val, ok, nil
+}
+
+// objectStoragePairs turns the flat list of objects and their data that
+// SplObjectStorage serializes into a list of obj/inf arrays.
+func (r *reader) objectStoragePairs(storage any) ([]any, bool) {
+	elems, ok := listElements(storage)
+	if !ok || len(elems)%2 != 0 {
+		return nil, false
+	}
+	pairs := make([]any, len(elems)/2)
+	for i := range pairs {
+		pairs[i] = r.objectStoragePair(elems[2*i], elems[2*i+1])
+	}
+	return pairs, true
+}
+
+func (r *reader) objectStoragePair(obj, inf any) any {
+	m := r.newArray(2)
+	setEntry(m, StringKey("obj"), obj)
+	setEntry(m, StringKey("inf"), inf)
+	return m
+}
+
+// fixedArrayElements returns the elements of an SplFixedArray, stored as
+// properties 0..N-1.
+func fixedArrayElements(obj any) ([]any, bool) {
+	entries, _, ok := mapEntries(obj)
+	if !ok {
+		return nil, false
+	}
+	n := 0
+	for _, e := range entries {
+		if e.IsInt {
+			n++
+		}
+	}
+	elems := make([]any, n)
+	filled := make([]bool, n)
+	for _, e := range entries {
+		if !e.IsInt {
+			continue
+		}
+		i, err := strconv.Atoi(e.Key)
+		if err != nil || i < 0 || i >= n || filled[i] {
+			return nil, false
+		}
+		elems[i], filled[i] = e.Value, true
+	}
+	return elems, true
+}
+
+// decodeSPLSerializable parses the data an SPL class wrote through the
+// Serializable interface before PHP 7.4. The values inside are in PHP
+// serialize() format.
+func (r *reader) decodeSPLSerializable(kind int, raw string) (any, bool, error) {
+	if err := r.enter(0); err != nil {
+		return nil, false, err
+	}
+	defer r.leave()
+
+	u := unserializer{r: r, data: []byte(raw)}
+	var val any
+	var err error
+	switch kind {
+	case splArrayObject:
+		val, err = u.arrayObject()
+	case splObjectStorage:
+		val, err = u.objectStorage()
+	case splDoublyLinkedList:
+		val, err = u.doublyLinkedList()
+	default:
+		return nil, false, nil
+	}
+	if err == nil && u.pos != len(u.data) {
+		err = u.syntaxError("trailing data")
+	}
+	if err != nil {
+		if isLimitError(err) {
+			return nil, false, err
+		}
+		return nil, false, nil
+	}
+	return val, true, nil
+}
+
+// arrayObject parses x:i:FLAGS;STORAGE;m:MEMBERS and returns the storage.
+func (u *unserializer) arrayObject() (any, error) {
+	if err := u.expect("x:"); err != nil {
+		return nil, err
+	}
+	if _, err := u.value(); err != nil {
+		return nil, err
+	}
+	storage, err := u.value()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.expect(";m:"); err != nil {
+		return nil, err
+	}
+	_, err = u.value()
+	return storage, err
+}
+
+// objectStorage parses x:i:COUNT;OBJECT,DATA;...;m:MEMBERS.
+func (u *unserializer) objectStorage() (any, error) {
+	if err := u.expect("x:"); err != nil {
+		return nil, err
+	}
+	count, err := u.value()
+	if err != nil {
+		return nil, err
+	}
+	n, ok := count.(int64)
+	if !ok || n < 0 || n > int64(len(u.data)) {
+		return nil, u.syntaxError("bad SplObjectStorage count")
+	}
+	if err := u.r.enter(int(n)); err != nil {
+		return nil, err
+	}
+	defer u.r.leave()
+
+	pairs := make([]any, n)
+	for i := range pairs {
+		obj, err := u.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := u.expect(","); err != nil {
+			return nil, err
+		}
+		inf, err := u.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := u.expect(";"); err != nil {
+			return nil, err
+		}
+		pairs[i] = u.r.objectStoragePair(obj, inf)
+	}
+	if err := u.expect("m:"); err != nil {
+		return nil, err
+	}
+	_, err = u.value()
+	return pairs, err
+}
+
+// doublyLinkedList parses i:FLAGS;:ELEMENT:ELEMENT...
+func (u *unserializer) doublyLinkedList() (any, error) {
+	if _, err := u.value(); err != nil {
+		return nil, err
+	}
+	elems := []any{}
+	for u.pos < len(u.data) {
+		if err := u.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := u.r.enter(1); err != nil {
+			return nil, err
+		}
+		u.r.leave()
+		elem, err := u.value()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
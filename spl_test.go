@@ -0,0 +1,172 @@
+package igbinary_test
+
+import (
+	"errors"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// splObject returns a payload holding an object of class with the given
+// properties, as PHP 7.4+ stores the result of __serialize().
+func splObject(t *testing.T, class string, props ...any) []byte {
+	t.Helper()
+	m := map[igbinary.Key]any{igbinary.StringKey(igbinary.ClassKey): class}
+	for i, p := range props {
+		m[igbinary.IntKey(int64(i))] = p
+	}
+	data, err := igbinary.Encode(m)
+	assertNoError(t, err)
+	return data
+}
+
+func decodeSPL(t *testing.T, data []byte) any {
+	t.Helper()
+	val, err := igbinary.NewDecoder(igbinary.WithSPL()).Decode(data)
+	assertNoError(t, err)
+	return val
+}
+
+func TestDecodeSPLArrayObject(t *testing.T) {
+	storage := map[string]any{"a": int64(1)}
+	for _, class := range []string{"ArrayObject", "ArrayIterator", "RecursiveArrayIterator"} {
+		val := decodeSPL(t, splObject(t, class, int64(0), storage, []any{}, nil))
+		m, ok := val.(map[string]any)
+		if !ok || len(m) != 1 {
+			t.Fatalf("%s: expected the wrapped array, got %#v", class, val)
+		}
+		assertEqualInt64(t, m["a"], 1)
+
+		val = decodeSPL(t, serializedObject(class, `x:i:0;a:1:{s:1:"a";i:1;};m:a:0:{}`))
+		m, ok = val.(map[string]any)
+		if !ok || len(m) != 1 {
+			t.Fatalf("%s legacy: expected the wrapped array, got %#v", class, val)
+		}
+		assertEqualInt64(t, m["a"], 1)
+	}
+}
+
+func TestDecodeSPLArrayObjectWrappingObject(t *testing.T) {
+	val := decodeSPL(t, serializedObject("ArrayObject", `x:i:0;O:8:"stdClass":1:{s:2:"id";i:7;};m:a:0:{}`))
+	m, ok := val.(map[string]any)
+	if !ok {
+		t.Fatalf("expected the wrapped object, got %#v", val)
+	}
+	assertEqualString(t, m[igbinary.ClassKey], "stdClass")
+	assertEqualInt64(t, m["id"], 7)
+}
+
+func TestDecodeSPLObjectStorage(t *testing.T) {
+	obj := map[string]any{igbinary.ClassKey: "stdClass", "id": int64(1)}
+	check := func(name string, val any) {
+		t.Helper()
+		pairs, ok := val.([]any)
+		if !ok || len(pairs) != 2 {
+			t.Fatalf("%s: expected 2 pairs, got %#v", name, val)
+		}
+		first := pairs[0].(map[string]any)
+		assertEqualString(t, first["obj"].(map[string]any)[igbinary.ClassKey], "stdClass")
+		assertEqualInt64(t, first["inf"], 5)
+		second := pairs[1].(map[string]any)
+		if second["inf"] != nil {
+			t.Errorf("%s: expected no data for the second object, got %#v", name, second["inf"])
+		}
+	}
+
+	check("__serialize", decodeSPL(t, splObject(t, "SplObjectStorage",
+		[]any{obj, int64(5), obj, nil}, []any{})))
+	check("legacy", decodeSPL(t, serializedObject("SplObjectStorage",
+		`x:i:2;O:8:"stdClass":1:{s:2:"id";i:1;},i:5;;O:8:"stdClass":0:{},N;;m:a:0:{}`)))
+}
+
+func TestDecodeSPLFixedArray(t *testing.T) {
+	val := decodeSPL(t, splObject(t, "SplFixedArray", "a", int64(2), nil))
+	s, ok := val.([]any)
+	if !ok || len(s) != 3 {
+		t.Fatalf("expected 3 elements, got %#v", val)
+	}
+	assertEqualString(t, s[0], "a")
+	assertEqualInt64(t, s[1], 2)
+	if s[2] != nil {
+		t.Errorf("expected nil, got %#v", s[2])
+	}
+
+	// Elements are not contiguous: keep the object.
+	data, err := igbinary.Encode(map[igbinary.Key]any{
+		igbinary.StringKey(igbinary.ClassKey): "SplFixedArray",
+		igbinary.IntKey(0):                    "a",
+		igbinary.IntKey(2):                    nil,
+	})
+	assertNoError(t, err)
+	if _, ok := decodeSPL(t, data).(map[string]any); !ok {
+		t.Error("expected a sparse SplFixedArray to stay an object")
+	}
+}
+
+func TestDecodeSPLDoublyLinkedList(t *testing.T) {
+	for _, class := range []string{"SplDoublyLinkedList", "SplQueue", "SplStack"} {
+		val := decodeSPL(t, splObject(t, class, int64(4), []any{int64(1), "a"}, []any{}))
+		s, ok := val.([]any)
+		if !ok || len(s) != 2 {
+			t.Fatalf("%s: expected 2 elements, got %#v", class, val)
+		}
+		assertEqualInt64(t, s[0], 1)
+		assertEqualString(t, s[1], "a")
+
+		val = decodeSPL(t, serializedObject(class, `i:4;:i:1;:s:1:"a";`))
+		s, ok = val.([]any)
+		if !ok || len(s) != 2 {
+			t.Fatalf("%s legacy: expected 2 elements, got %#v", class, val)
+		}
+		assertEqualInt64(t, s[0], 1)
+		assertEqualString(t, s[1], "a")
+	}
+
+	val := decodeSPL(t, serializedObject("SplQueue", `i:4;`))
+	if s, ok := val.([]any); !ok || len(s) != 0 {
+		t.Errorf("expected an empty list, got %#v", val)
+	}
+}
+
+func TestDecodeSPLMalformed(t *testing.T) {
+	for _, data := range []string{`x:i:0;`, `x:i:3;N;,N;;m:a:0:{}`, `junk`} {
+		val := decodeSPL(t, serializedObject("ArrayObject", data))
+		m, ok := val.(map[string]any)
+		if !ok || m[igbinary.SerializedDataKey] != data {
+			t.Errorf("%s: expected the object to be kept, got %#v", data, val)
+		}
+	}
+}
+
+func TestDecodeSPLOptIn(t *testing.T) {
+	val, err := igbinary.Decode(splObject(t, "SplQueue", int64(4), []any{int64(1)}, []any{}))
+	assertNoError(t, err)
+	if _, ok := val.(map[string]any); !ok {
+		t.Errorf("expected an object without WithSPL, got %#v", val)
+	}
+
+	v, err := igbinary.NewDecoder(igbinary.WithSPL()).DecodeValue(serializedObject("SplQueue", `i:4;:i:1;`))
+	assertNoError(t, err)
+	if k := v.Kind(); k != igbinary.Serialized {
+		t.Errorf("expected the tree to keep the object, got kind %v", k)
+	}
+}
+
+func TestDecodeSPLRegisteredClass(t *testing.T) {
+	type queue struct{}
+	dec := igbinary.NewDecoder(igbinary.WithSPL())
+	dec.RegisterClass("SplQueue", &queue{})
+	val, err := dec.Decode(splObject(t, "SplQueue", int64(4), []any{}, []any{}))
+	assertNoError(t, err)
+	if _, ok := val.(*queue); !ok {
+		t.Errorf("expected the registered type, got %#v", val)
+	}
+}
+
+func TestDecodeSPLLimits(t *testing.T) {
+	dec := igbinary.NewDecoder(igbinary.WithSPL(), igbinary.WithMaxDepth(2))
+	_, err := dec.Decode(serializedObject("ArrayObject", `x:i:0;a:1:{i:0;a:1:{i:0;a:0:{}}};m:a:0:{}`))
+	if !errors.Is(err, igbinary.ErrMaxDepthExceeded) {
+		t.Errorf("expected ErrMaxDepthExceeded, got %v", err)
+	}
+}
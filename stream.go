@@ -0,0 +1,266 @@
+package igbinary
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+)
+
+// DefaultMaxDocumentSize is the largest document a [StreamDecoder] buffers
+// unless configured otherwise with [StreamDecoder.SetMaxDocumentSize].
+const DefaultMaxDocumentSize = 64 << 20
+
+// StreamDecoder reads a sequence of igbinary documents from an io.Reader,
+// such as a file of cache snapshots written one after the other:
+//
+//	sd := igbinary.NewStreamDecoder(f)
+//	for sd.More() {
+//	    v, err := sd.Decode()
+//	    if err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+//
+// Documents are either concatenated, each starting with its own igbinary
+// header, or preceded by a 4-byte big-endian length after
+// [StreamDecoder.UseLengthPrefix]. Only the current document is buffered,
+// up to the size set with [StreamDecoder.SetMaxDocumentSize]. Error
+// positions are offsets in the stream.
+//
+// A StreamDecoder is not safe for concurrent use.
+type StreamDecoder struct {
+	r        io.Reader
+	dec      *Decoder
+	buf      []byte // buffered input; buf[start:] is not decoded yet
+	start    int
+	offset   int64 // stream offset of buf[0]
+	eof      bool  // r returned io.EOF
+	err      error // sticky error returned by every later Decode
+	prefixed bool
+	maxSize  int
+}
+
+// NewStreamDecoder returns a StreamDecoder reading documents from r and
+// decoding them with the given options, as a [Decoder] would.
+func NewStreamDecoder(r io.Reader, opts ...Option) *StreamDecoder {
+	return &StreamDecoder{r: r, dec: NewDecoder(opts...), maxSize: DefaultMaxDocumentSize}
+}
+
+// UseLengthPrefix makes the decoder expect each document to be preceded by
+// its length in bytes, header included, as a 4-byte big-endian integer.
+// A document that does not fill its length exactly is reported as
+// [ErrTrailingData].
+func (s *StreamDecoder) UseLengthPrefix() {
+	s.prefixed = true
+}
+
+// SetMaxDocumentSize limits the size of a single document, and so the
+// memory the decoder buffers. Larger documents fail with
+// [ErrDocumentTooLarge].
+func (s *StreamDecoder) SetMaxDocumentSize(n int) {
+	s.maxSize = n
+}
+
+// RegisterClass registers a class as [Decoder.RegisterClass] does.
+func (s *StreamDecoder) RegisterClass(class string, prototype any) {
+	s.dec.RegisterClass(class, prototype)
+}
+
+// InputOffset returns the stream offset just after the last decoded
+// document.
+func (s *StreamDecoder) InputOffset() int64 {
+	return s.offset + int64(s.start)
+}
+
+// More reports whether another document follows. It also returns true when
+// reading failed, so that the next Decode reports the error.
+func (s *StreamDecoder) More() bool {
+	if s.err != nil {
+		return s.err != io.EOF
+	}
+	err := s.fill(1)
+	return err != nil || len(s.buf) > s.start
+}
+
+// Decode decodes the next document. It returns io.EOF once the stream ends
+// cleanly, and the same error on every call after a decoding error.
+func (s *StreamDecoder) Decode() (any, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	v, err := s.decode()
+	if err != nil {
+		s.err = err
+		return nil, err
+	}
+	return v, nil
+}
+
+// DecodeInto decodes the next document into the Go value pointed to by v,
+// following the rules of [Decoder.DecodeInto].
+func (s *StreamDecoder) DecodeInto(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return invalidTargetError(v)
+	}
+	val, err := s.Decode()
+	if err != nil {
+		return err
+	}
+	return assign(rv.Elem(), val, nil)
+}
+
+func (s *StreamDecoder) decode() (any, error) {
+	doc, skip, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	base := s.InputOffset() + int64(skip)
+	if s.dec.zeroCopy || s.dec.byteStrings {
+		// The buffer is reused for the next documents.
+		doc = append([]byte(nil), doc...)
+	}
+
+	v, n, err := s.dec.DecodeAt(doc, 0)
+	if err == nil && n < len(doc) {
+		err = newError(ErrTrailingData, n,
+			fmt.Sprintf("%d bytes after the root value", len(doc)-n))
+	}
+	if err != nil {
+		var de *DecodeError
+		if errors.As(err, &de) {
+			de.shift(int(base))
+		}
+		return nil, err
+	}
+	s.start += skip + len(doc)
+	return v, nil
+}
+
+// next returns the next document in the buffer, and the number of bytes
+// before it.
+func (s *StreamDecoder) next() (doc []byte, skip int, err error) {
+	if s.prefixed {
+		return s.nextPrefixed()
+	}
+
+	want := 5
+	if s.dec.noHeader {
+		want = 1
+	}
+	for {
+		if err := s.fill(want); err != nil {
+			return nil, 0, err
+		}
+		avail := s.buf[s.start:]
+		if len(avail) == 0 {
+			return nil, 0, io.EOF
+		}
+
+		n, err := s.dec.measure(avail)
+		if err == nil {
+			if n > s.maxSize {
+				return nil, 0, s.tooLarge(n)
+			}
+			return avail[:n], 0, nil
+		}
+		truncated := errors.Is(err, ErrUnexpectedEnd) || errors.Is(err, ErrDataTooShort)
+		if !truncated || s.eof {
+			var de *DecodeError
+			if errors.As(err, &de) {
+				de.shift(int(s.InputOffset()))
+			}
+			return nil, 0, err
+		}
+		if len(avail) >= s.maxSize {
+			return nil, 0, s.tooLarge(len(avail) + 1)
+		}
+		want = min(2*len(avail), s.maxSize)
+	}
+}
+
+func (s *StreamDecoder) nextPrefixed() ([]byte, int, error) {
+	if err := s.fill(4); err != nil {
+		return nil, 0, err
+	}
+	switch avail := len(s.buf) - s.start; {
+	case avail == 0:
+		return nil, 0, io.EOF
+	case avail < 4:
+		return nil, 0, newError(ErrUnexpectedEnd, int(s.InputOffset()), "length prefix")
+	}
+
+	size := binary.BigEndian.Uint32(s.buf[s.start:])
+	if uint64(size) > uint64(s.maxSize) {
+		return nil, 0, s.tooLarge(int(size))
+	}
+	if err := s.fill(4 + int(size)); err != nil {
+		return nil, 0, err
+	}
+	if avail := len(s.buf) - s.start - 4; avail < int(size) {
+		return nil, 0, newError(ErrUnexpectedEnd, int(s.InputOffset())+4,
+			fmt.Sprintf("document of %d bytes, have %d", size, avail))
+	}
+	return s.buf[s.start+4 : s.start+4+int(size)], 4, nil
+}
+
+func (s *StreamDecoder) tooLarge(n int) error {
+	return newError(ErrDocumentTooLarge, int(s.InputOffset()),
+		fmt.Sprintf("%d bytes, limit %d", n, s.maxSize))
+}
+
+// fill reads until at least n bytes are buffered after start, or the
+// stream ends.
+func (s *StreamDecoder) fill(n int) error {
+	if len(s.buf)-s.start >= n || s.eof {
+		return nil
+	}
+	if s.start > 0 {
+		s.offset += int64(s.start)
+		s.buf = s.buf[:copy(s.buf, s.buf[s.start:])]
+		s.start = 0
+	}
+	for len(s.buf) < n {
+		if len(s.buf) == cap(s.buf) {
+			// Grow with the data actually read, not with n: a corrupt
+			// length prefix must not allocate its claimed size up front.
+			s.buf = slices.Grow(s.buf, streamChunk)
+		}
+		m, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+m]
+		if err == io.EOF {
+			s.eof = true
+			return nil
+		}
+		if err != nil {
+			s.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// shift moves the positions of e by offset.
+func (e *DecodeError) shift(offset int) {
+	e.Pos += offset
+	if e.CodePos >= 0 {
+		e.CodePos += offset
+	}
+}
+
+// measure returns the length of the payload at the start of data, reading
+// it without building any values.
+func (d *Decoder) measure(data []byte) (int, error) {
+	r, err := d.newReader(data)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.skipValue(); err != nil {
+		return 0, err
+	}
+	return r.pos, nil
+}
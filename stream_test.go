@@ -0,0 +1,154 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// streamDocs are three documents: int(1), "hello" and ["a" => 2].
+var streamDocs = [][]byte{
+	makePayload(0x06, 0x01),
+	makePayload(0x11, 0x05, 'h', 'e', 'l', 'l', 'o'),
+	makePayload(0x14, 0x01, 0x11, 0x01, 'a', 0x06, 0x02),
+}
+
+func decodeAll(t *testing.T, sd *igbinary.StreamDecoder) []any {
+	t.Helper()
+	var vals []any
+	for sd.More() {
+		v, err := sd.Decode()
+		assertNoError(t, err)
+		vals = append(vals, v)
+	}
+	if _, err := sd.Decode(); err != io.EOF {
+		t.Fatalf("expected io.EOF after the last document, got %v", err)
+	}
+	return vals
+}
+
+func assertStreamDocs(t *testing.T, vals []any) {
+	t.Helper()
+	if len(vals) != 3 {
+		t.Fatalf("expected 3 documents, got %d", len(vals))
+	}
+	assertEqualInt64(t, vals[0], 1)
+	assertEqualString(t, vals[1], "hello")
+	assertEqualInt64(t, vals[2].(map[string]any)["a"], 2)
+}
+
+func TestStreamDecoderConcatenated(t *testing.T) {
+	data := bytes.Join(streamDocs, nil)
+	// One byte per Read exercises every refill path.
+	sd := igbinary.NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+	assertStreamDocs(t, decodeAll(t, sd))
+	if sd.InputOffset() != int64(len(data)) {
+		t.Errorf("expected offset %d, got %d", len(data), sd.InputOffset())
+	}
+}
+
+func TestStreamDecoderLengthPrefixed(t *testing.T) {
+	var data []byte
+	for _, doc := range streamDocs {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(doc)))
+		data = append(data, doc...)
+	}
+	sd := igbinary.NewStreamDecoder(bytes.NewReader(data))
+	sd.UseLengthPrefix()
+	assertStreamDocs(t, decodeAll(t, sd))
+}
+
+func TestStreamDecoderLengthMismatch(t *testing.T) {
+	data := binary.BigEndian.AppendUint32(nil, 7)
+	data = append(data, makePayload(0x06, 0x01, 0x00)...)
+	sd := igbinary.NewStreamDecoder(bytes.NewReader(data))
+	sd.UseLengthPrefix()
+	_, err := sd.Decode()
+	assertDecodeError(t, err, igbinary.ErrTrailingData)
+}
+
+func TestStreamDecoderEmpty(t *testing.T) {
+	sd := igbinary.NewStreamDecoder(strings.NewReader(""))
+	if sd.More() {
+		t.Error("expected no documents")
+	}
+	if _, err := sd.Decode(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestStreamDecoderInvalidHeader(t *testing.T) {
+	data := append(append([]byte(nil), streamDocs[0]...), 0x01, 0x02, 0x03, 0x04, 0x05)
+	sd := igbinary.NewStreamDecoder(bytes.NewReader(data))
+	_, err := sd.Decode()
+	assertNoError(t, err)
+	_, err = sd.Decode()
+	assertDecodeError(t, err, igbinary.ErrInvalidHeader)
+	var de *igbinary.DecodeError
+	if errors.As(err, &de) && de.Pos != 6 {
+		t.Errorf("expected stream offset 6, got %d", de.Pos)
+	}
+	if !sd.More() {
+		t.Error("expected More to report the pending error")
+	}
+	if _, again := sd.Decode(); again != err {
+		t.Errorf("expected the same error again, got %v", again)
+	}
+}
+
+func TestStreamDecoderTruncated(t *testing.T) {
+	data := streamDocs[1][:8]
+	sd := igbinary.NewStreamDecoder(bytes.NewReader(data))
+	_, err := sd.Decode()
+	assertDecodeError(t, err, igbinary.ErrUnexpectedEnd)
+}
+
+func TestStreamDecoderMaxDocumentSize(t *testing.T) {
+	sd := igbinary.NewStreamDecoder(bytes.NewReader(bytes.Join(streamDocs, nil)))
+	sd.SetMaxDocumentSize(8)
+	_, err := sd.Decode()
+	assertNoError(t, err)
+	_, err = sd.Decode()
+	assertDecodeError(t, err, igbinary.ErrDocumentTooLarge)
+
+	// A forged length prefix fails before anything is read for it.
+	data := binary.BigEndian.AppendUint32(nil, 0xFFFFFFFF)
+	sd = igbinary.NewStreamDecoder(bytes.NewReader(data))
+	sd.UseLengthPrefix()
+	_, err = sd.Decode()
+	assertDecodeError(t, err, igbinary.ErrDocumentTooLarge)
+}
+
+func TestStreamDecoderReadError(t *testing.T) {
+	boom := errors.New("boom")
+	sd := igbinary.NewStreamDecoder(iotest.ErrReader(boom))
+	if !sd.More() {
+		t.Error("expected More to report the read error")
+	}
+	if _, err := sd.Decode(); !errors.Is(err, boom) {
+		t.Errorf("expected read error, got %v", err)
+	}
+}
+
+func TestStreamDecoderZeroCopyStrings(t *testing.T) {
+	data := bytes.Join(streamDocs, nil)
+	sd := igbinary.NewStreamDecoder(bytes.NewReader(data), igbinary.WithZeroCopyStrings())
+	assertStreamDocs(t, decodeAll(t, sd))
+}
+
+func TestStreamDecoderDecodeInto(t *testing.T) {
+	var doc struct {
+		A int `igbinary:"a"`
+	}
+	sd := igbinary.NewStreamDecoder(bytes.NewReader(streamDocs[2]))
+	assertNoError(t, sd.DecodeInto(&doc))
+	if doc.A != 2 {
+		t.Errorf("expected 2, got %d", doc.A)
+	}
+}
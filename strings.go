@@ -0,0 +1,59 @@
+package igbinary
+
+import "unsafe"
+
+// WithZeroCopyStrings makes the decoder return strings that share memory
+// with the input instead of copying each one out of it. This saves an
+// allocation and a copy per string, which adds up for payloads with many or
+// large strings.
+//
+// The caller must keep the input alive and must not modify it for as long as
+// any decoded string, map key or class name is in use: Go strings are
+// assumed to be immutable, and changing the bytes under them breaks map
+// lookups and comparisons in ways that are hard to track down. Reusing the
+// input buffer for the next payload, as pooled buffers do, counts as
+// modifying it. Copy the strings you keep beyond that point with
+// [strings.Clone].
+func WithZeroCopyStrings() Option {
+	return func(d *Decoder) {
+		d.zeroCopy = true
+	}
+}
+
+// WithByteStrings makes the decoder return PHP string values as []byte
+// instead of string, which suits payloads carrying binary blobs. The slices
+// are subslices of the input, so the same lifetime rules apply as for
+// [WithZeroCopyStrings]; their capacity is capped, so appending to one
+// never overwrites the input. Array keys, property names and class names
+// are still returned as strings.
+//
+// [Value.Kind] reports such values as [String], and [Decoder.DecodeInto]
+// stores them in string and []byte fields alike.
+func WithByteStrings() Option {
+	return func(d *Decoder) {
+		d.byteStrings = true
+	}
+}
+
+// makeString converts bytes read from the input to a string, taking it
+// from the intern pool if there is one, and copying the bytes unless
+// zero-copy strings are enabled.
+func (r *reader) makeString(b []byte) string {
+	if r.intern != nil {
+		if s, ok := r.intern.lookup(b); ok {
+			return s
+		}
+	}
+	if r.zeroCopy && len(b) > 0 {
+		return unsafe.String(unsafe.SliceData(b), len(b))
+	}
+	return string(b)
+}
+
+// stringValue returns the string with table ID id as a decoded value.
+func (r *reader) stringValue(id int, s string) any {
+	if r.byteStrings {
+		return r.stringBytes[id]
+	}
+	return s
+}
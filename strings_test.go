@@ -0,0 +1,81 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// blobPayload is ["key" => "blob", "copy" => "blob" (string ID 1)].
+var blobPayload = makePayload(
+	0x14, 0x02,
+	0x11, 0x03, 'k', 'e', 'y', 0x11, 0x04, 'b', 'l', 'o', 'b',
+	0x11, 0x04, 'c', 'o', 'p', 'y', 0x0E, 0x01,
+)
+
+func TestZeroCopyStringsShareInput(t *testing.T) {
+	data := makePayload(0x11, 0x05, 'h', 'e', 'l', 'l', 'o')
+	val, err := igbinary.NewDecoder(igbinary.WithZeroCopyStrings()).Decode(data)
+	assertNoError(t, err)
+	assertEqualString(t, val, "hello")
+	if unsafe.StringData(val.(string)) != &data[6] {
+		t.Error("expected the string to point into the input")
+	}
+}
+
+func TestZeroCopyStringsDedup(t *testing.T) {
+	val, err := igbinary.NewDecoder(igbinary.WithZeroCopyStrings()).Decode(blobPayload)
+	assertNoError(t, err)
+	m := val.(map[string]any)
+	assertEqualString(t, m["key"], "blob")
+	assertEqualString(t, m["copy"], "blob")
+}
+
+func TestByteStrings(t *testing.T) {
+	val, err := igbinary.NewDecoder(igbinary.WithByteStrings()).Decode(blobPayload)
+	assertNoError(t, err)
+	m, ok := val.(map[string]any)
+	if !ok {
+		t.Fatalf("expected map[string]any, got %T", val)
+	}
+	for _, k := range []string{"key", "copy"} {
+		b, ok := m[k].([]byte)
+		if !ok || !bytes.Equal(b, []byte("blob")) {
+			t.Errorf("%s: expected []byte(\"blob\"), got %#v", k, m[k])
+			continue
+		}
+		if cap(b) != len(b) {
+			t.Errorf("%s: expected capped slice, got cap %d", k, cap(b))
+		}
+	}
+}
+
+func TestByteStringsEmpty(t *testing.T) {
+	val, err := igbinary.NewDecoder(igbinary.WithByteStrings()).Decode(makePayload(0x0D))
+	assertNoError(t, err)
+	if b, ok := val.([]byte); !ok || len(b) != 0 {
+		t.Errorf("expected empty []byte, got %#v", val)
+	}
+}
+
+func TestByteStringsValue(t *testing.T) {
+	v, err := igbinary.NewDecoder(igbinary.WithByteStrings()).DecodeValue(blobPayload)
+	assertNoError(t, err)
+	if k := v.Get("key").Kind(); k != igbinary.String {
+		t.Errorf("expected kind string, got %v", k)
+	}
+	assertEqualString(t, v.Get("copy").Str(), "blob")
+}
+
+func TestByteStringsDecodeInto(t *testing.T) {
+	var out struct {
+		Key  string `igbinary:"key"`
+		Copy []byte `igbinary:"copy"`
+	}
+	dec := igbinary.NewDecoder(igbinary.WithByteStrings())
+	assertNoError(t, dec.DecodeInto(blobPayload, &out))
+	assertEqualString(t, out.Key, "blob")
+	assertEqualString(t, string(out.Copy), "blob")
+}
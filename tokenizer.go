@@ -0,0 +1,610 @@
+package igbinary
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// TokenKind identifies the type of a [Token].
+type TokenKind int
+
+// Kinds of [Token].
+const (
+	// TokenScalar is a PHP NULL, bool, int, float or string in Token.Value
+	// (nil, bool, int64, float64 or string).
+	TokenScalar TokenKind = iota + 1
+	// TokenKey is the key of the next array entry or object property.
+	TokenKey
+	// TokenArrayStart opens an array of Token.Len entries. Each entry is a
+	// TokenKey followed by its value; a TokenEnd closes the array.
+	TokenArrayStart
+	// TokenObjectStart opens an object of class Token.Class with Token.Len
+	// properties, laid out like array entries.
+	TokenObjectStart
+	// TokenSerialized is an object of class Token.Class stored through
+	// Serializable or __serialize, with its raw data in Token.Value.
+	TokenSerialized
+	// TokenEnd closes the innermost array or object.
+	TokenEnd
+	// TokenRef is a back-reference to the array, object or PHP reference
+	// whose Token.ID it carries.
+	TokenRef
+)
+
+var tokenKindNames = [...]string{
+	TokenScalar:      "scalar",
+	TokenKey:         "key",
+	TokenArrayStart:  "array start",
+	TokenObjectStart: "object start",
+	TokenSerialized:  "serialized object",
+	TokenEnd:         "end",
+	TokenRef:         "ref",
+}
+
+// String returns a lower-case description of the token kind.
+func (k TokenKind) String() string {
+	if k > 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// Token is a single element of an igbinary payload, as returned by
+// [Tokenizer.Next]. Only the fields documented for its Kind are set.
+type Token struct {
+	Kind  TokenKind
+	Key   Key    // TokenKey
+	Value any    // TokenScalar, TokenSerialized
+	Class string // TokenObjectStart, TokenSerialized
+	Len   int    // TokenArrayStart, TokenObjectStart
+
+	// ID is the position of an array, object or serialized object in the
+	// payload's value table, or the position a TokenRef refers to.
+	ID int
+	// Object reports that a TokenRef refers to an object rather than an array.
+	Object bool
+	// Reference reports that the value is a PHP reference (&$var). A
+	// referenced TokenScalar then has an ID as well, which later TokenRef
+	// tokens may refer to.
+	Reference bool
+}
+
+// Tokenizer reads an igbinary payload one token at a time, without building
+// the decoded tree. It keeps only the string table and the nesting of the
+// current position, so arrays with many thousands of rows can be processed
+// row by row:
+//
+//	tok := igbinary.NewTokenizer(data)
+//	start, err := tok.Next() // TokenArrayStart
+//	for i := 0; i < start.Len; i++ {
+//	    key, _ := tok.Next()       // TokenKey
+//	    row, err := tok.Decode()   // the row's value
+//	    ...
+//	}
+//
+// Next returns io.EOF once the root value has been read completely. A
+// Tokenizer is not safe for concurrent use.
+type Tokenizer struct {
+	src     byteSource
+	pos     int
+	started bool
+	done    bool
+	err     error
+	strings []string
+	nvalues int
+	stack   []tokenFrame
+
+	maxDepth int // nesting limit of Decode, 0 for none
+}
+
+// tokenFrame is an array or object the tokenizer is currently inside.
+type tokenFrame struct {
+	remaining int  // entries not yet read
+	wantValue bool // a key was read and its value is next
+}
+
+// NewTokenizer returns a Tokenizer reading the igbinary payload in data,
+// which must include the 4-byte header.
+func NewTokenizer(data []byte) *Tokenizer {
+	return defaultDecoder.NewTokenizer(data)
+}
+
+// NewReaderTokenizer returns a Tokenizer reading an igbinary payload,
+// including its header, from r. Only the current token is held in memory.
+func NewReaderTokenizer(r io.Reader) *Tokenizer {
+	return defaultDecoder.NewReaderTokenizer(r)
+}
+
+// NewTokenizer is like the package-level [NewTokenizer], with
+// [Tokenizer.Decode] following the decoder's [WithMaxDepth] limit.
+func (d *Decoder) NewTokenizer(data []byte) *Tokenizer {
+	return &Tokenizer{src: &sliceSource{data: data}, maxDepth: d.limits.maxDepth}
+}
+
+// NewReaderTokenizer is like the package-level [NewReaderTokenizer], with
+// [Tokenizer.Decode] following the decoder's [WithMaxDepth] limit.
+func (d *Decoder) NewReaderTokenizer(r io.Reader) *Tokenizer {
+	return &Tokenizer{src: &streamSource{r: bufio.NewReader(r)}, maxDepth: d.limits.maxDepth}
+}
+
+// Depth returns the number of arrays and objects enclosing the next token.
+func (t *Tokenizer) Depth() int {
+	return len(t.stack)
+}
+
+// Next returns the next token. It returns io.EOF after the root value, and
+// the same error on every call after a decoding error.
+func (t *Tokenizer) Next() (Token, error) {
+	if t.err != nil {
+		return Token{}, t.err
+	}
+	tok, err := t.next()
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			if t.pos == 4 {
+				err = newError(ErrDataTooShort, 0, "need at least 5 bytes")
+			} else {
+				err = newError(ErrUnexpectedEnd, t.pos, "")
+			}
+		}
+		t.err = err
+		return Token{}, err
+	}
+	return tok, nil
+}
+
+func (t *Tokenizer) next() (Token, error) {
+	if !t.started {
+		if err := t.readHeader(); err != nil {
+			return Token{}, err
+		}
+		t.started = true
+	}
+	if t.done {
+		return Token{}, io.EOF
+	}
+
+	if n := len(t.stack); n > 0 && !t.stack[n-1].wantValue {
+		top := &t.stack[n-1]
+		if top.remaining == 0 {
+			t.stack = t.stack[:n-1]
+			t.valueDone()
+			return Token{Kind: TokenEnd}, nil
+		}
+		key, err := t.readKey()
+		if err != nil {
+			return Token{}, err
+		}
+		top.wantValue = true
+		return Token{Kind: TokenKey, Key: key}, nil
+	}
+
+	tok, err := t.readValue()
+	if err != nil {
+		return Token{}, err
+	}
+	if tok.Kind == TokenArrayStart || tok.Kind == TokenObjectStart {
+		t.stack = append(t.stack, tokenFrame{remaining: tok.Len})
+	} else {
+		t.valueDone()
+	}
+	return tok, nil
+}
+
+// valueDone records that a complete value was read at the current position.
+func (t *Tokenizer) valueDone() {
+	n := len(t.stack)
+	if n == 0 {
+		t.done = true
+		return
+	}
+	t.stack[n-1].wantValue = false
+	t.stack[n-1].remaining--
+}
+
+func (t *Tokenizer) readHeader() error {
+	b, err := t.read(4)
+	if err != nil {
+		return newError(ErrDataTooShort, 0, "need at least 5 bytes")
+	}
+	if b[0] != 0x00 || b[1] != 0x00 || b[2] != 0x00 || b[3] != FormatVersion {
+		return newError(ErrInvalidHeader,
+			0, fmt.Sprintf("got %02x %02x %02x %02x, want 00 00 00 %02x",
+				b[0], b[1], b[2], b[3], FormatVersion))
+	}
+	return nil
+}
+
+// read returns the next n bytes, valid until the next read.
+func (t *Tokenizer) read(n int) ([]byte, error) {
+	b, err := t.src.next(n)
+	t.pos += len(b)
+	return b, err
+}
+
+// readSized reads a big-endian length or ID of 1, 2 or 4 bytes, selected by
+// the offset of code from the 8-bit variant base of its type code group.
+func (t *Tokenizer) readSized(code, base byte) (int, error) {
+	switch code - base {
+	case 0:
+		b, err := t.read(1)
+		if err != nil {
+			return 0, err
+		}
+		return int(b[0]), nil
+	case 1:
+		b, err := t.read(2)
+		if err != nil {
+			return 0, err
+		}
+		return int(binary.BigEndian.Uint16(b)), nil
+	default:
+		b, err := t.read(4)
+		if err != nil {
+			return 0, err
+		}
+		return int(binary.BigEndian.Uint32(b)), nil
+	}
+}
+
+// readUint reads a big-endian unsigned integer of n bytes.
+func (t *Tokenizer) readUint(n int) (uint64, error) {
+	b, err := t.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// readInt reads the payload of an integer type code.
+func (t *Tokenizer) readInt(code byte) (int64, error) {
+	var size int
+	switch code {
+	case TypePosInt8, TypeNegInt8:
+		size = 1
+	case TypePosInt16, TypeNegInt16:
+		size = 2
+	case TypePosInt32, TypeNegInt32:
+		size = 4
+	default:
+		size = 8
+	}
+	v, err := t.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	switch code {
+	case TypeNegInt8, TypeNegInt16, TypeNegInt32, TypeNegInt64:
+		return -int64(v), nil
+	}
+	return int64(v), nil
+}
+
+// readNewString reads a string of length n and registers it.
+func (t *Tokenizer) readNewString(n int) (string, error) {
+	b, err := t.read(n)
+	if err != nil {
+		return "", err
+	}
+	s := string(b)
+	t.strings = append(t.strings, s)
+	return s, nil
+}
+
+// readString reads the payload of a string type code.
+func (t *Tokenizer) readString(code byte) (string, error) {
+	switch code {
+	case TypeStringEmpty:
+		return "", nil
+	case TypeString8, TypeString16, TypeString32:
+		n, err := t.readSized(code, TypeString8)
+		if err != nil {
+			return "", err
+		}
+		return t.readNewString(n)
+	default:
+		id, err := t.readSized(code, TypeStringID8)
+		if err != nil {
+			return "", err
+		}
+		return t.lookupString(id)
+	}
+}
+
+func (t *Tokenizer) lookupString(id int) (string, error) {
+	if id < 0 || id >= len(t.strings) {
+		return "", newError(ErrStringIDOutOfRange, t.pos,
+			fmt.Sprintf("ID %d, table size %d", id, len(t.strings)))
+	}
+	return t.strings[id], nil
+}
+
+func (t *Tokenizer) readKey() (Key, error) {
+	code, err := t.read(1)
+	if err != nil {
+		return Key{}, err
+	}
+	switch c := code[0]; c {
+	case TypeStringEmpty, TypeString8, TypeString16, TypeString32,
+		TypeStringID8, TypeStringID16, TypeStringID32:
+		s, err := t.readString(c)
+		return StringKey(s), err
+	case TypePosInt8, TypeNegInt8, TypePosInt16, TypeNegInt16,
+		TypePosInt32, TypeNegInt32, TypePosInt64, TypeNegInt64:
+		i, err := t.readInt(c)
+		return IntKey(i), err
+	default:
+		return Key{}, newError(ErrUnsupportedArrayKey, t.pos-1, fmt.Sprintf("0x%02x", c))
+	}
+}
+
+func (t *Tokenizer) readValue() (Token, error) {
+	b, err := t.read(1)
+	if err != nil {
+		return Token{}, err
+	}
+	code := b[0]
+	switch code {
+	case TypeNil:
+		return Token{Kind: TokenScalar}, nil
+	case TypeBoolFalse, TypeBoolTrue:
+		return Token{Kind: TokenScalar, Value: code == TypeBoolTrue}, nil
+
+	case TypePosInt8, TypeNegInt8, TypePosInt16, TypeNegInt16,
+		TypePosInt32, TypeNegInt32, TypePosInt64, TypeNegInt64:
+		i, err := t.readInt(code)
+		return Token{Kind: TokenScalar, Value: i}, err
+
+	case TypeDouble:
+		v, err := t.readUint(8)
+		return Token{Kind: TokenScalar, Value: math.Float64frombits(v)}, err
+
+	case TypeStringEmpty, TypeString8, TypeString16, TypeString32,
+		TypeStringID8, TypeStringID16, TypeStringID32:
+		s, err := t.readString(code)
+		return Token{Kind: TokenScalar, Value: s}, err
+
+	case TypeArray8, TypeArray16, TypeArray32:
+		n, err := t.readSized(code, TypeArray8)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TokenArrayStart, Len: n, ID: t.newValueID()}, nil
+
+	case TypeObject8, TypeObject16, TypeObject32:
+		n, err := t.readSized(code, TypeObject8)
+		if err != nil {
+			return Token{}, err
+		}
+		class, err := t.readNewString(n)
+		if err != nil {
+			return Token{}, err
+		}
+		return t.readObjectProperties(class)
+
+	case TypeObjectID8, TypeObjectID16, TypeObjectID32:
+		id, err := t.readSized(code, TypeObjectID8)
+		if err != nil {
+			return Token{}, err
+		}
+		class, err := t.lookupString(id)
+		if err != nil {
+			return Token{}, fmt.Errorf("object class ID: %w", err)
+		}
+		return t.readObjectProperties(class)
+
+	case TypeObjectSer8, TypeObjectSer16, TypeObjectSer32:
+		return t.readSerialized(code)
+
+	case TypeArrayRef8, TypeArrayRef16, TypeArrayRef32:
+		id, err := t.readSized(code, TypeArrayRef8)
+		return Token{Kind: TokenRef, ID: id}, err
+	case TypeObjectRef8, TypeObjectRef16, TypeObjectRef32:
+		id, err := t.readSized(code, TypeObjectRef8)
+		return Token{Kind: TokenRef, ID: id, Object: true}, err
+
+	case TypeSimpleRef:
+		tok, err := t.readValue()
+		if err != nil {
+			return Token{}, err
+		}
+		if tok.Kind == TokenScalar && !tok.Reference {
+			tok.ID = t.newValueID()
+		}
+		tok.Reference = true
+		return tok, nil
+
+	default:
+		return Token{}, newError(ErrUnknownType, t.pos-1, fmt.Sprintf("0x%02x", code))
+	}
+}
+
+func (t *Tokenizer) readObjectProperties(class string) (Token, error) {
+	b, err := t.read(1)
+	if err != nil {
+		return Token{}, err
+	}
+	code := b[0]
+	if code != TypeArray8 && code != TypeArray16 && code != TypeArray32 {
+		return Token{}, newError(ErrInvalidObjectProperties, t.pos-1,
+			fmt.Sprintf("expected array type code, got 0x%02x", code))
+	}
+	n, err := t.readSized(code, TypeArray8)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Kind: TokenObjectStart, Class: class, Len: n, ID: t.newValueID()}, nil
+}
+
+func (t *Tokenizer) readSerialized(code byte) (Token, error) {
+	n, err := t.readSized(code, TypeObjectSer8)
+	if err != nil {
+		return Token{}, err
+	}
+	class, err := t.readNewString(n)
+	if err != nil {
+		return Token{}, err
+	}
+	b, err := t.read(1)
+	if err != nil {
+		return Token{}, err
+	}
+	if c := b[0]; c != TypeString8 && c != TypeString16 && c != TypeString32 {
+		return Token{}, newError(ErrInvalidSerializedData, t.pos-1,
+			fmt.Sprintf("expected string type code, got 0x%02x", c))
+	}
+	n, err = t.readSized(b[0], TypeString8)
+	if err != nil {
+		return Token{}, err
+	}
+	raw, err := t.read(n)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Kind: TokenSerialized, Class: class, Value: string(raw), ID: t.newValueID()}, nil
+}
+
+// newValueID assigns the next value table position to a compound value or
+// a referenced scalar.
+func (t *Tokenizer) newValueID() int {
+	id := t.nvalues
+	t.nvalues++
+	return id
+}
+
+// Decode reads the next complete value and returns it using the same types
+// as [Decode]. It must be called where a value is expected: at the start, or
+// after a TokenKey. Back-references to arrays and objects outside the value
+// decode as nil. Arrays and objects nested more than [DefaultMaxDepth]
+// levels deep within the value, or the limit of the [Decoder] that created
+// the Tokenizer, fail with [ErrMaxDepthExceeded].
+func (t *Tokenizer) Decode() (any, error) {
+	if n := len(t.stack); n > 0 && !t.stack[n-1].wantValue {
+		return nil, fmt.Errorf("igbinary: Tokenizer.Decode called where a key or end is expected")
+	}
+	tok, err := t.Next()
+	if err != nil {
+		return nil, err
+	}
+	return t.build(tok, make(map[int]any), 0)
+}
+
+// build assembles the value starting with tok, reading the tokens of any
+// nested arrays and objects. depth is the number of arrays and objects
+// enclosing tok within the value being decoded.
+func (t *Tokenizer) build(tok Token, values map[int]any, depth int) (any, error) {
+	switch tok.Kind {
+	case TokenScalar:
+		if tok.Reference {
+			values[tok.ID] = tok.Value
+		}
+		return tok.Value, nil
+	case TokenRef:
+		return values[tok.ID], nil
+	case TokenSerialized:
+		m := map[string]any{ClassKey: tok.Class, SerializedDataKey: tok.Value}
+		values[tok.ID] = m
+		return m, nil
+	}
+
+	if t.maxDepth > 0 && depth >= t.maxDepth {
+		return nil, newError(ErrMaxDepthExceeded, t.pos,
+			fmt.Sprintf("limit %d", t.maxDepth))
+	}
+	m := make(map[string]any, tok.Len)
+	if tok.Kind == TokenObjectStart {
+		m[ClassKey] = tok.Class
+	}
+	values[tok.ID] = m
+	for {
+		key, err := t.Next()
+		if err != nil {
+			return nil, err
+		}
+		if key.Kind == TokenEnd {
+			return m, nil
+		}
+		next, err := t.Next()
+		if err != nil {
+			return nil, err
+		}
+		val, err := t.build(next, values, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		m[key.Key.String()] = val
+	}
+}
+
+// byteSource supplies the bytes of a payload to a Tokenizer.
+type byteSource interface {
+	// next returns the next n bytes, valid until the next call. It returns
+	// io.ErrUnexpectedEOF when fewer than n bytes are left, and other errors
+	// of the underlying reader wrapped.
+	next(n int) ([]byte, error)
+}
+
+// sliceSource reads from a byte slice without copying.
+type sliceSource struct {
+	data []byte
+	pos  int
+}
+
+func (s *sliceSource) next(n int) ([]byte, error) {
+	if n > len(s.data)-s.pos {
+		s.pos = len(s.data)
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := s.data[s.pos : s.pos+n]
+	s.pos += n
+	return b, nil
+}
+
+// streamSource reads from an io.Reader, reusing one buffer for the bytes of
+// the current token.
+type streamSource struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// streamChunk bounds how far the buffer grows ahead of the data actually
+// read, so that a corrupt length cannot force a huge allocation.
+const streamChunk = 64 << 10
+
+func (s *streamSource) next(n int) ([]byte, error) {
+	if n == 1 {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return nil, readError(err)
+		}
+		s.buf = append(s.buf[:0], c)
+		return s.buf, nil
+	}
+	s.buf = s.buf[:0]
+	for len(s.buf) < n {
+		chunk := min(n-len(s.buf), streamChunk)
+		start := len(s.buf)
+		s.buf = append(s.buf, make([]byte, chunk)...)
+		if _, err := io.ReadFull(s.r, s.buf[start:]); err != nil {
+			return nil, readError(err)
+		}
+	}
+	return s.buf, nil
+}
+
+// readError maps an error of the underlying reader: running out of input
+// means the payload is truncated, any other error is the reader's own.
+func readError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("igbinary: read: %w", err)
+}
@@ -0,0 +1,168 @@
+package igbinary_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+// collectTokens reads all tokens until io.EOF.
+func collectTokens(t *testing.T, tok *igbinary.Tokenizer) []igbinary.Token {
+	t.Helper()
+	var tokens []igbinary.Token
+	for {
+		tk, err := tok.Next()
+		if err == io.EOF {
+			return tokens
+		}
+		assertNoError(t, err)
+		tokens = append(tokens, tk)
+	}
+}
+
+func TestTokenizerTokens(t *testing.T) {
+	node := map[string]any{igbinary.ClassKey: "Node", "id": int64(1)}
+	node["self"] = node
+	data, err := igbinary.Encode([]any{node, "x"})
+	assertNoError(t, err)
+
+	want := []igbinary.Token{
+		{Kind: igbinary.TokenArrayStart, Len: 2, ID: 0},
+		{Kind: igbinary.TokenKey, Key: igbinary.IntKey(0)},
+		{Kind: igbinary.TokenObjectStart, Class: "Node", Len: 2, ID: 1},
+		{Kind: igbinary.TokenKey, Key: igbinary.StringKey("id")},
+		{Kind: igbinary.TokenScalar, Value: int64(1)},
+		{Kind: igbinary.TokenKey, Key: igbinary.StringKey("self")},
+		{Kind: igbinary.TokenRef, ID: 1, Object: true},
+		{Kind: igbinary.TokenEnd},
+		{Kind: igbinary.TokenKey, Key: igbinary.IntKey(1)},
+		{Kind: igbinary.TokenScalar, Value: "x"},
+		{Kind: igbinary.TokenEnd},
+	}
+	for name, tok := range map[string]*igbinary.Tokenizer{
+		"bytes":  igbinary.NewTokenizer(data),
+		"reader": igbinary.NewReaderTokenizer(bytes.NewReader(data)),
+	} {
+		if got := collectTokens(t, tok); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v\nwant %+v", name, got, want)
+		}
+	}
+}
+
+func TestTokenizerStringTable(t *testing.T) {
+	// ["a" => "v", "b" => "v"]: the second "v" is a back-reference.
+	data, err := igbinary.Encode(map[string]any{"a": "v", "b": "v"})
+	assertNoError(t, err)
+
+	tokens := collectTokens(t, igbinary.NewReaderTokenizer(bytes.NewReader(data)))
+	if got := tokens[4].Value; got != "v" {
+		t.Errorf("expected back-referenced string v, got %#v", got)
+	}
+}
+
+func TestTokenizerSerialized(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{
+		igbinary.ClassKey: "Money", igbinary.SerializedDataKey: "raw",
+	})
+	assertNoError(t, err)
+
+	tokens := collectTokens(t, igbinary.NewTokenizer(data))
+	want := []igbinary.Token{{Kind: igbinary.TokenSerialized, Class: "Money", Value: "raw"}}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got %+v", tokens)
+	}
+}
+
+func TestTokenizerDecodeRows(t *testing.T) {
+	rows := make([]any, 3)
+	for i := range rows {
+		rows[i] = map[string]any{"id": int64(i), "tags": []any{"a"}}
+	}
+	data, err := igbinary.Encode(rows)
+	assertNoError(t, err)
+
+	tok := igbinary.NewReaderTokenizer(bytes.NewReader(data))
+	start, err := tok.Next()
+	assertNoError(t, err)
+	for i := 0; i < start.Len; i++ {
+		if _, err := tok.Next(); err != nil {
+			t.Fatal(err)
+		}
+		row, err := tok.Decode()
+		assertNoError(t, err)
+		assertEqualInt64(t, row.(map[string]any)["id"], int64(i))
+		if tok.Depth() != 1 {
+			t.Errorf("expected depth 1 after row, got %d", tok.Depth())
+		}
+	}
+	end, err := tok.Next()
+	assertNoError(t, err)
+	if end.Kind != igbinary.TokenEnd {
+		t.Errorf("expected end, got %s", end.Kind)
+	}
+	if _, err := tok.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestTokenizerDecodeWhereKeyExpected(t *testing.T) {
+	tok := igbinary.NewTokenizer(makePayload(0x14, 0x01, 0x06, 0x00, 0x00))
+	_, err := tok.Next()
+	assertNoError(t, err)
+	if _, err := tok.Decode(); err == nil {
+		t.Error("expected error when a key is expected")
+	}
+}
+
+func TestTokenizerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"short", []byte{0x00, 0x00, 0x00, 0x02}, igbinary.ErrDataTooShort},
+		{"header", []byte{0x00, 0x00, 0x00, 0x01, 0x00}, igbinary.ErrInvalidHeader},
+		{"truncated", makePayload(0x14, 0x02, 0x06, 0x00, 0x00), igbinary.ErrUnexpectedEnd},
+		{"unknown", makePayload(0xff), igbinary.ErrUnknownType},
+		{"string id", makePayload(0x0e, 0x05), igbinary.ErrStringIDOutOfRange},
+	}
+	for _, tt := range tests {
+		for _, tok := range []*igbinary.Tokenizer{
+			igbinary.NewTokenizer(tt.data),
+			igbinary.NewReaderTokenizer(bytes.NewReader(tt.data)),
+		} {
+			var err error
+			for err == nil {
+				_, err = tok.Next()
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+			}
+			if _, again := tok.Next(); again != err {
+				t.Errorf("%s: expected sticky error, got %v", tt.name, again)
+			}
+		}
+	}
+}
+
+func TestReaderTokenizerReadError(t *testing.T) {
+	boom := errors.New("boom")
+	for name, data := range map[string][]byte{
+		"type code": makePayload(0x14, 0x01),
+		"string":    makePayload(0x14, 0x01, 0x06, 0x00, 0x11, 0x05, 'a', 'b'),
+	} {
+		tok := igbinary.NewReaderTokenizer(io.MultiReader(bytes.NewReader(data), iotest.ErrReader(boom)))
+		var err error
+		for err == nil {
+			_, err = tok.Next()
+		}
+		if !errors.Is(err, boom) || errors.Is(err, igbinary.ErrUnexpectedEnd) {
+			t.Errorf("%s: expected the read error, got %v", name, err)
+		}
+	}
+}
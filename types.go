@@ -107,3 +107,7 @@ const ClassKey = "__class"
 // SerializedDataKey is the map key used to store raw serialized data
 // when decoding objects that implement PHP's Serializable interface.
 const SerializedDataKey = "__serialized_raw"
+
+// SerializedValueKey is the map key used to store the decoded data of
+// serialized objects, with [WithDecodeSerialized].
+const SerializedValueKey = "__serialized"
@@ -0,0 +1,559 @@
+package igbinary
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Unmarshal decodes igbinary-serialized data and stores the result in the
+// value pointed to by v.
+//
+// This is a convenience wrapper around [Decoder.DecodeInto] using default options.
+func Unmarshal(data []byte, v any) error {
+	return defaultDecoder.DecodeInto(data, v)
+}
+
+// DecodeInto decodes igbinary-serialized data and stores the result in the
+// value pointed to by v, which must be a non-nil pointer.
+//
+// PHP values are assigned to Go types as follows:
+//
+//   - PHP NULL sets the target to its zero value (nil for pointers, maps and slices).
+//   - PHP booleans, integers, floats and strings fill Go values of the matching
+//     kind. Integers also fill floats; strings also fill []byte. Integers that
+//     overflow the target type are reported as errors.
+//   - PHP arrays with keys 0..N-1 fill slices and arrays.
+//   - PHP arrays and objects fill maps with string, integer or [Key] keys, and
+//     structs.
+//   - Any value fills an empty interface, using the same types as [Decoder.Decode].
+//   - Types implementing [Unmarshaler] decode themselves.
+//   - PHP DateTime and DateTimeZone objects fill time.Time and *time.Location.
+//   - A PHP array or object that contains itself through back-references
+//     fills a Go value with the same cycle through pointers. When the cycle
+//     would not pass through a pointer, DecodeInto returns [ErrCyclicValue].
+//
+// Struct fields are matched against PHP keys by their igbinary tag name, or by
+// the Go field name (exact, then case-insensitive) when untagged:
+//
+//	type User struct {
+//	    ID    int64    `igbinary:"id"`
+//	    Email string   `igbinary:"email,omitempty"`
+//	    Tags  []string `igbinary:"tags"`
+//	    Cache string   `igbinary:"-"`
+//	}
+//
+// A tag of "-" skips the field. Fields of embedded structs are promoted.
+// PHP keys without a matching field are ignored.
+//
+// When a PHP value cannot be assigned to the target, DecodeInto returns an
+// [*UnmarshalTypeError] naming the path of the offending value, such as
+// "$.orders[12].customer.id".
+func (d *Decoder) DecodeInto(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return invalidTargetError(v)
+	}
+
+	val, err := d.Decode(data)
+	if err != nil {
+		return err
+	}
+	return assign(rv.Elem(), val, nil)
+}
+
+// invalidTargetError reports a DecodeInto target that is not a non-nil pointer.
+func invalidTargetError(v any) error {
+	return fmt.Errorf("%w: got %T", ErrInvalidTarget, v)
+}
+
+// assignPath is a linked list of keys leading from the root value to the one
+// currently being assigned. It is only rendered when an error occurs.
+type assignPath struct {
+	parent *assignPath
+	key    string
+}
+
+func (p *assignPath) child(key string) *assignPath {
+	return &assignPath{parent: p, key: key}
+}
+
+// String renders the path as "$", "$.name", "$.list[3]" and so on.
+func (p *assignPath) String() string {
+	var keys []string
+	for n := p; n != nil; n = n.parent {
+		keys = append(keys, n.key)
+	}
+	var b strings.Builder
+	b.WriteString("$")
+	for i := len(keys) - 1; i >= 0; i-- {
+		writePathKey(&b, keys[i])
+	}
+	return b.String()
+}
+
+// writePathKey appends one path step: ".name" for identifier-like keys,
+// "[3]" for integer keys and ["quoted key"] for anything else.
+func writePathKey(b *strings.Builder, key string) {
+	if _, ok := parseIntKey(key); ok {
+		b.WriteString("[" + key + "]")
+		return
+	}
+	if isIdentifier(key) {
+		b.WriteString("." + key)
+		return
+	}
+	b.WriteString("[" + strconv.Quote(key) + "]")
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// typeError builds an UnmarshalTypeError for src not fitting dst.
+func typeError(src any, dst reflect.Type, path *assignPath) error {
+	return &UnmarshalTypeError{Value: phpTypeName(src), Type: dst, Path: path.String()}
+}
+
+// phpTypeName describes a decoded value using PHP type names.
+func phpTypeName(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string, []byte:
+		return "string"
+	case map[string]any:
+		if class, ok := val[ClassKey].(string); ok {
+			return "object(" + class + ")"
+		}
+		return "array"
+	case *OrderedMap:
+		if class, ok := val.Get(ClassKey); ok {
+			return fmt.Sprintf("object(%v)", class)
+		}
+		return "array"
+	case map[Key]any:
+		if class, ok := val[StringKey(ClassKey)].(string); ok {
+			return "object(" + class + ")"
+		}
+		return "array"
+	case []any:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// assign stores the decoded value src into dst.
+func assign(dst reflect.Value, src any, path *assignPath) error {
+	var a assigner
+	return a.assign(dst, src, path)
+}
+
+// assigner holds the state of one assign call: the PHP arrays and objects
+// currently being assigned, so that the cycles back-references create in
+// decoded data cannot recurse forever.
+type assigner struct {
+	// ptrs holds the pointer allocated for each container being assigned
+	// through a pointer; meeting the container again inside itself reuses
+	// it, so the Go value gets the same cycle.
+	ptrs map[assignKey]reflect.Value
+
+	// active holds the containers being assigned to non-pointer values,
+	// which cannot hold a cycle.
+	active map[assignKey]bool
+}
+
+// assignKey identifies a container being assigned to a Go type.
+type assignKey struct {
+	src uintptr
+	typ reflect.Type
+}
+
+// containerKey returns the key of src assigned to typ, and false when src is
+// neither a map nor a pointer, the only decoded values that can be shared.
+func containerKey(src any, typ reflect.Type) (assignKey, bool) {
+	switch sv := reflect.ValueOf(src); sv.Kind() {
+	case reflect.Map, reflect.Pointer:
+		return assignKey{src: sv.Pointer(), typ: typ}, true
+	}
+	return assignKey{}, false
+}
+
+// setPtr records p as the pointer for key unless one is recorded already,
+// and reports whether it did.
+func (a *assigner) setPtr(key assignKey, p reflect.Value) bool {
+	if _, ok := a.ptrs[key]; ok {
+		return false
+	}
+	if a.ptrs == nil {
+		a.ptrs = make(map[assignKey]reflect.Value)
+	}
+	a.ptrs[key] = p
+	return true
+}
+
+func (a *assigner) assign(dst reflect.Value, src any, path *assignPath) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if ref, ok := src.(*Reference); ok {
+		return a.assign(dst, ref.Target, path)
+	}
+
+	if u, ok := unmarshalerFor(dst); ok {
+		if err := u.UnmarshalIgbinary(Value{v: src}); err != nil {
+			return fmt.Errorf("igbinary: UnmarshalIgbinary for %s at %s: %w", dst.Type(), path, err)
+		}
+		return nil
+	}
+
+	// Decoded values that already have the target type (int64, string,
+	// map[string]any, ...) are stored as-is.
+	if sv := reflect.ValueOf(src); sv.Type().AssignableTo(dst.Type()) {
+		dst.Set(sv)
+		return nil
+	}
+	if ok, err := assignDateTime(dst, src, path); ok {
+		return err
+	}
+
+	key, isContainer := containerKey(src, dst.Type())
+	if isContainer && dst.Kind() != reflect.Pointer {
+		if a.active[key] {
+			return fmt.Errorf("%w: %s at %s contains itself", ErrCyclicValue, dst.Type(), path)
+		}
+		if a.active == nil {
+			a.active = make(map[assignKey]bool)
+		}
+		a.active[key] = true
+		defer delete(a.active, key)
+		if dst.CanAddr() {
+			// A pointer to dst's type met inside src can point to dst.
+			ptrKey := assignKey{src: key.src, typ: reflect.PointerTo(dst.Type())}
+			if a.setPtr(ptrKey, dst.Addr()) {
+				defer delete(a.ptrs, ptrKey)
+			}
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		if isContainer {
+			if p, ok := a.ptrs[key]; ok {
+				dst.Set(p)
+				return nil
+			}
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		if isContainer && a.setPtr(key, dst) {
+			defer delete(a.ptrs, key)
+		}
+		return a.assign(dst.Elem(), src, path)
+
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return typeError(src, dst.Type(), path)
+		}
+		dst.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := src.(int64)
+		if !ok || dst.OverflowInt(i) {
+			return typeError(src, dst.Type(), path)
+		}
+		dst.SetInt(i)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := src.(int64)
+		if !ok || i < 0 || dst.OverflowUint(uint64(i)) {
+			return typeError(src, dst.Type(), path)
+		}
+		dst.SetUint(uint64(i))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch n := src.(type) {
+		case float64:
+			dst.SetFloat(n)
+		case int64:
+			dst.SetFloat(float64(n))
+		default:
+			return typeError(src, dst.Type(), path)
+		}
+		return nil
+
+	case reflect.String:
+		switch s := src.(type) {
+		case string:
+			dst.SetString(s)
+		case []byte:
+			dst.SetString(string(s))
+		default:
+			return typeError(src, dst.Type(), path)
+		}
+		return nil
+
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			if s, ok := src.(string); ok {
+				dst.SetBytes([]byte(s))
+				return nil
+			}
+		}
+		elems, ok := listElements(src)
+		if !ok {
+			return typeError(src, dst.Type(), path)
+		}
+		s := reflect.MakeSlice(dst.Type(), len(elems), len(elems))
+		for i, e := range elems {
+			if err := a.assign(s.Index(i), e, path.child(strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+
+	case reflect.Array:
+		elems, ok := listElements(src)
+		if !ok || len(elems) > dst.Len() {
+			return typeError(src, dst.Type(), path)
+		}
+		for i := 0; i < dst.Len(); i++ {
+			if i >= len(elems) {
+				dst.Index(i).Set(reflect.Zero(dst.Type().Elem()))
+				continue
+			}
+			if err := a.assign(dst.Index(i), elems[i], path.child(strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		return a.assignMap(dst, src, path)
+
+	case reflect.Struct:
+		return a.assignStruct(dst, src, path)
+	}
+
+	return typeError(src, dst.Type(), path)
+}
+
+// listElements returns the elements of a sequential PHP array (keys 0..N-1),
+// either as decoded or after normalization.
+func listElements(src any) ([]any, bool) {
+	switch val := src.(type) {
+	case []any:
+		return val, true
+	case map[string]any:
+		if _, isObject := val[ClassKey]; isObject {
+			return nil, false
+		}
+		elems := make([]any, len(val))
+		for i := range elems {
+			e, ok := val[strconv.Itoa(i)]
+			if !ok {
+				return nil, false
+			}
+			elems[i] = e
+		}
+		return elems, true
+	case map[Key]any:
+		if _, isObject := val[StringKey(ClassKey)]; isObject {
+			return nil, false
+		}
+		elems := make([]any, len(val))
+		for i := range elems {
+			e, ok := val[IntKey(int64(i))]
+			if !ok {
+				return nil, false
+			}
+			elems[i] = e
+		}
+		return elems, true
+	case *OrderedMap:
+		if _, isObject := val.Get(ClassKey); isObject {
+			return nil, false
+		}
+		elems := make([]any, val.Len())
+		for i, e := range val.Entries() {
+			if e.Key != strconv.Itoa(i) {
+				return nil, false
+			}
+			elems[i] = e.Value
+		}
+		return elems, true
+	}
+	return nil, false
+}
+
+// mapEntries returns the entries of a PHP array or object in a deterministic
+// order (insertion order for an *OrderedMap, sorted keys for maps), along
+// with the class name when src is an object. The class entry itself is
+// omitted.
+func mapEntries(src any) (entries []Entry, class string, ok bool) {
+	switch val := src.(type) {
+	case map[string]any:
+		class, _ = val[ClassKey].(string)
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			if class != "" && k == ClassKey {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries = make([]Entry, len(keys))
+		for i, k := range keys {
+			_, isInt := parseIntKey(k)
+			entries[i] = Entry{Key: k, Value: val[k], IsInt: isInt}
+		}
+		return entries, class, true
+	case map[Key]any:
+		class, _ = val[StringKey(ClassKey)].(string)
+		keys := make([]Key, 0, len(val))
+		for k := range val {
+			if class != "" && k == StringKey(ClassKey) {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sortKeyKinds(keys)
+		entries = make([]Entry, len(keys))
+		for i, k := range keys {
+			entries[i] = Entry{Key: k.String(), Value: val[k], IsInt: k.IsInt}
+		}
+		return entries, class, true
+	case *OrderedMap:
+		if c, ok := val.Get(ClassKey); ok {
+			class, _ = c.(string)
+		}
+		entries = make([]Entry, 0, val.Len())
+		for _, e := range val.Entries() {
+			if class != "" && e.Key == ClassKey {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		return entries, class, true
+	case []any:
+		entries = make([]Entry, len(val))
+		for i, v := range val {
+			entries[i] = Entry{Key: strconv.Itoa(i), Value: v, IsInt: true}
+		}
+		return entries, "", true
+	}
+	return nil, "", false
+}
+
+// assignMap fills a Go map with string or integer keys from a PHP array.
+func (a *assigner) assignMap(dst reflect.Value, src any, path *assignPath) error {
+	entries, _, ok := mapEntries(src)
+	if !ok {
+		return typeError(src, dst.Type(), path)
+	}
+
+	mt := dst.Type()
+	switch mt.Key().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		if mt.Key() != keyType {
+			return typeError(src, mt, path)
+		}
+	}
+
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(mt, len(entries)))
+	}
+	for _, e := range entries {
+		k := e.Key
+		kp := path.child(k)
+		kv := reflect.New(mt.Key()).Elem()
+		switch mt.Key().Kind() {
+		case reflect.Struct:
+			kv.Set(reflect.ValueOf(e.PHPKey()))
+		case reflect.String:
+			kv.SetString(k)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, ok := parseIntKey(k)
+			if !ok || kv.OverflowInt(i) {
+				return &UnmarshalTypeError{Value: "key " + strconv.Quote(k), Type: mt.Key(), Path: kp.String()}
+			}
+			kv.SetInt(i)
+		default:
+			i, ok := parseIntKey(k)
+			if !ok || i < 0 || kv.OverflowUint(uint64(i)) {
+				return &UnmarshalTypeError{Value: "key " + strconv.Quote(k), Type: mt.Key(), Path: kp.String()}
+			}
+			kv.SetUint(uint64(i))
+		}
+
+		ev := reflect.New(mt.Elem()).Elem()
+		if err := a.assign(ev, e.Value, kp); err != nil {
+			return err
+		}
+		dst.SetMapIndex(kv, ev)
+	}
+	return nil
+}
+
+// assignStruct fills a Go struct from a PHP array or object.
+func (a *assigner) assignStruct(dst reflect.Value, src any, path *assignPath) error {
+	entries, class, ok := mapEntries(src)
+	if !ok {
+		return typeError(src, dst.Type(), path)
+	}
+
+	fields := cachedFields(dst.Type())
+	for _, e := range entries {
+		f, ok := fields.lookup(e.Key)
+		if !ok {
+			continue
+		}
+		fv, err := fieldByIndex(dst, f.index)
+		if err != nil {
+			return fmt.Errorf("%w at %s", err, path.child(e.Key))
+		}
+		if err := a.assign(fv, e.Value, path.child(e.Key)); err != nil {
+			return err
+		}
+	}
+	if class != "" {
+		if f, ok := fields.byName[ClassKey]; ok {
+			fv, err := fieldByIndex(dst, fields.list[f].index)
+			if err != nil {
+				return fmt.Errorf("%w at %s", err, path.child(ClassKey))
+			}
+			return a.assign(fv, class, path.child(ClassKey))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package igbinary_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+type unmarshalAddress struct {
+	City string `igbinary:"city"`
+	Zip  string `igbinary:"zip,omitempty"`
+}
+
+type unmarshalBase struct {
+	ID int64 `igbinary:"id"`
+}
+
+type unmarshalUser struct {
+	unmarshalBase
+	Name    string            `igbinary:"name"`
+	Tags    []string          `igbinary:"tags"`
+	Scores  map[int]float64   `igbinary:"scores"`
+	Extra   map[string]string `igbinary:"extra"`
+	Address *unmarshalAddress `igbinary:"address"`
+	Active  bool
+	Ignored string `igbinary:"-"`
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{
+		"id":      int64(7),
+		"name":    "Alice",
+		"tags":    []any{"a", "b"},
+		"scores":  map[string]any{"3": 1.5, "9": int64(2)},
+		"extra":   map[string]any{"k": "v"},
+		"address": map[string]any{"city": "Berlin"},
+		"active":  true,
+		"Ignored": "nope",
+		"unknown": "skipped",
+	})
+	assertNoError(t, err)
+
+	var u unmarshalUser
+	assertNoError(t, igbinary.Unmarshal(data, &u))
+
+	want := unmarshalUser{
+		unmarshalBase: unmarshalBase{ID: 7},
+		Name:          "Alice",
+		Tags:          []string{"a", "b"},
+		Scores:        map[int]float64{3: 1.5, 9: 2},
+		Extra:         map[string]string{"k": "v"},
+		Address:       &unmarshalAddress{City: "Berlin"},
+		Active:        true,
+	}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("got %+v, want %+v", u, want)
+	}
+}
+
+func TestUnmarshalObjectIntoStruct(t *testing.T) {
+	// Object "Foo" with property "x" => 1
+	data := makePayload(
+		0x17, 0x03, 'F', 'o', 'o',
+		0x14, 0x01,
+		0x11, 0x01, 'x',
+		0x06, 0x01,
+	)
+	var v struct {
+		Class string `igbinary:"__class"`
+		X     int    `igbinary:"x"`
+	}
+	assertNoError(t, igbinary.Unmarshal(data, &v))
+	if v.Class != "Foo" || v.X != 1 {
+		t.Errorf("unexpected result: %+v", v)
+	}
+}
+
+func TestUnmarshalSliceOfStructs(t *testing.T) {
+	data, err := igbinary.Encode([]any{
+		map[string]any{"city": "Paris"},
+		map[string]any{"city": "Rome", "zip": "00100"},
+	})
+	assertNoError(t, err)
+
+	var out []unmarshalAddress
+	assertNoError(t, igbinary.Unmarshal(data, &out))
+	want := []unmarshalAddress{{City: "Paris"}, {City: "Rome", Zip: "00100"}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("got %+v, want %+v", out, want)
+	}
+}
+
+func TestUnmarshalIntoInterface(t *testing.T) {
+	data := makePayload(0x06, 0x2A)
+	var v any
+	assertNoError(t, igbinary.Unmarshal(data, &v))
+	assertEqualInt64(t, v, 42)
+}
+
+func TestUnmarshalNullResetsTarget(t *testing.T) {
+	data := makePayload(0x00)
+	p := &unmarshalAddress{City: "x"}
+	assertNoError(t, igbinary.Unmarshal(data, &p))
+	if p != nil {
+		t.Errorf("expected nil pointer, got %+v", p)
+	}
+}
+
+func TestUnmarshalTypeMismatchReportsPath(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{
+		"orders": []any{
+			map[string]any{"id": int64(1)},
+			map[string]any{"id": "oops"},
+		},
+	})
+	assertNoError(t, err)
+
+	var v struct {
+		Orders []struct {
+			ID int `igbinary:"id"`
+		} `igbinary:"orders"`
+	}
+	err = igbinary.Unmarshal(data, &v)
+	if !errors.Is(err, igbinary.ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got: %v", err)
+	}
+	var te *igbinary.UnmarshalTypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected *UnmarshalTypeError, got %T", err)
+	}
+	if te.Path != "$.orders[1].id" {
+		t.Errorf("expected path $.orders[1].id, got %q", te.Path)
+	}
+	if te.Value != "string" || te.Type.Kind() != reflect.Int {
+		t.Errorf("unexpected error details: %+v", te)
+	}
+}
+
+func TestUnmarshalIntegerOverflow(t *testing.T) {
+	data := makePayload(0x08, 0x01, 0x00) // 256
+	var v int8
+	if err := igbinary.Unmarshal(data, &v); !errors.Is(err, igbinary.ErrTypeMismatch) {
+		t.Errorf("expected ErrTypeMismatch, got: %v", err)
+	}
+}
+
+func TestUnmarshalNonSequentialArrayIntoSlice(t *testing.T) {
+	data, err := igbinary.Encode(map[int]string{0: "a", 2: "c"})
+	assertNoError(t, err)
+	var v []string
+	if err := igbinary.Unmarshal(data, &v); !errors.Is(err, igbinary.ErrTypeMismatch) {
+		t.Errorf("expected ErrTypeMismatch, got: %v", err)
+	}
+}
+
+func TestUnmarshalInvalidTarget(t *testing.T) {
+	data := makePayload(0x06, 0x01)
+	var v int
+	for _, target := range []any{nil, v, (*int)(nil)} {
+		if err := igbinary.Unmarshal(data, target); !errors.Is(err, igbinary.ErrInvalidTarget) {
+			t.Errorf("%T: expected ErrInvalidTarget, got: %v", target, err)
+		}
+	}
+}
+
+func TestUnmarshalPropagatesDecodeError(t *testing.T) {
+	var v any
+	err := igbinary.Unmarshal([]byte{0x00}, &v)
+	if !errors.Is(err, igbinary.ErrDataTooShort) {
+		t.Errorf("expected ErrDataTooShort, got: %v", err)
+	}
+}
+
+func TestDecodeIntoWithNormalizedArrays(t *testing.T) {
+	data, err := igbinary.Encode([]any{int64(1), int64(2)})
+	assertNoError(t, err)
+
+	dec := igbinary.NewDecoder(igbinary.WithNormalizeArrays())
+	var v [3]int
+	assertNoError(t, dec.DecodeInto(data, &v))
+	if v != [3]int{1, 2, 0} {
+		t.Errorf("unexpected result: %v", v)
+	}
+}
+
+type cyclicNode struct {
+	Name string
+	Self *cyclicNode
+}
+
+// cyclicObject is the payload of $o = new Node; $o->name = "x"; $o->self = $o.
+var cyclicObject = makePayload(
+	0x17, 0x04, 'N', 'o', 'd', 'e', 0x14, 0x02,
+	0x11, 0x04, 'n', 'a', 'm', 'e', 0x11, 0x01, 'x',
+	0x11, 0x04, 's', 'e', 'l', 'f', 0x22, 0x00,
+)
+
+func TestUnmarshalCyclicObject(t *testing.T) {
+	var n cyclicNode
+	assertNoError(t, igbinary.Unmarshal(cyclicObject, &n))
+	assertEqualString(t, n.Name, "x")
+	if n.Self != &n {
+		t.Errorf("expected Self to point to the node itself, got %p", n.Self)
+	}
+
+	var p *cyclicNode
+	assertNoError(t, igbinary.Unmarshal(cyclicObject, &p))
+	if p == nil || p.Self != p {
+		t.Errorf("expected Self to point to the node itself, got %+v", p)
+	}
+
+	v, err := igbinary.DecodeValue(cyclicObject)
+	assertNoError(t, err)
+	var fromTree cyclicNode
+	assertNoError(t, v.Decode(&fromTree))
+	if fromTree.Self != &fromTree {
+		t.Errorf("expected Self to point to the node itself, got %p", fromTree.Self)
+	}
+}
+
+func TestUnmarshalCyclicValueWithoutPointer(t *testing.T) {
+	type loop []loop
+	// An array whose only element is a back-reference to the array itself.
+	data := makePayload(0x14, 0x01, 0x06, 0x00, 0x01, 0x00)
+	var l loop
+	err := igbinary.Unmarshal(data, &l)
+	if !errors.Is(err, igbinary.ErrCyclicValue) {
+		t.Errorf("expected ErrCyclicValue, got %v", err)
+	}
+}
+
+type unexportedInner struct {
+	X int64 `igbinary:"x"`
+}
+
+type unexportedOuter struct {
+	*unexportedInner
+	Y int64 `igbinary:"y"`
+}
+
+func TestUnmarshalNilUnexportedEmbeddedPointer(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{"x": int64(1), "y": int64(2)})
+	assertNoError(t, err)
+
+	var out unexportedOuter
+	err = igbinary.Unmarshal(data, &out)
+	if !errors.Is(err, igbinary.ErrUnexportedEmbeddedPointer) {
+		t.Errorf("expected ErrUnexportedEmbeddedPointer, got %v", err)
+	}
+
+	// An embedded pointer set by the caller is filled.
+	out = unexportedOuter{unexportedInner: &unexportedInner{}}
+	assertNoError(t, igbinary.Unmarshal(data, &out))
+	assertEqualInt64(t, out.X, 1)
+	assertEqualInt64(t, out.Y, 2)
+
+	// Without keys for the embedded struct, it is left alone.
+	data, err = igbinary.Encode(map[string]any{"y": int64(3)})
+	assertNoError(t, err)
+	out = unexportedOuter{}
+	assertNoError(t, igbinary.Unmarshal(data, &out))
+	assertEqualInt64(t, out.Y, 3)
+}
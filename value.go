@@ -0,0 +1,519 @@
+package igbinary
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// Value is a decoded igbinary value. [Decoder.DecodeValue] returns the root
+// of a Value tree, and [Unmarshaler] implementations receive one.
+//
+// Accessors never panic: called on a value of another kind they return the
+// zero value (0, "", a Null Value, ...). Accessors on a [Ref] value act on
+// the value it refers to.
+type Value struct {
+	v    any
+	tree bool // part of a DecodeValue or DecodeLazy tree, whose objects are *object nodes
+}
+
+// object is a PHP object in a [Value] tree. The class and the data of a
+// serialized object live in their own fields, so that no property name, nor
+// any array key, is mistaken for them.
+type object struct {
+	class   string
+	props   *OrderedMap // nil for a serialized object
+	raw     string      // the data of a serialized object
+	value   any         // raw decoded, when decoded is set
+	decoded bool
+}
+
+// Kind is the PHP type of a [Value].
+type Kind int
+
+// Kinds of [Value].
+const (
+	Invalid    Kind = iota // not a PHP value, e.g. a Go type from RegisterClass or a placeholder
+	Null                   // PHP NULL
+	Bool                   // PHP bool
+	Int                    // PHP int
+	Float                  // PHP float
+	String                 // PHP string
+	Array                  // PHP array
+	Object                 // PHP object
+	Serialized             // PHP object stored via Serializable or __serialize
+	Ref                    // back-reference to an earlier array or object, or a PHP reference
+)
+
+var kindNames = [...]string{
+	Invalid:    "invalid",
+	Null:       "null",
+	Bool:       "bool",
+	Int:        "int",
+	Float:      "float",
+	String:     "string",
+	Array:      "array",
+	Object:     "object",
+	Serialized: "serialized",
+	Ref:        "ref",
+}
+
+// String returns the lower-case name of the kind.
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "Kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Reference is a back-reference to a PHP array or object that appeared
+// earlier in the same payload, or a PHP reference (&$var) when Simple is
+// set. [Decoder.DecodeValue] keeps references as Ref values instead of
+// sharing the target, so that cyclic data can be walked and encoded again.
+//
+// Every slot holding the same PHP reference holds the same *Reference, so
+// aliasing is visible as pointer equality. [Encode] writes the first
+// occurrence of a simple Reference as a PHP reference to its target and
+// later ones as back-references to it; other References are written as
+// back-references to their target, which must have been written before.
+type Reference struct {
+	Object bool // an object reference rather than an array reference
+	Simple bool // a PHP reference (&$var) rather than a back-reference
+	Target any  // the referenced value
+}
+
+// KeyValue is one entry of an array or object [Value].
+type KeyValue struct {
+	Key   Key
+	Value Value
+}
+
+// DecodeValue decodes igbinary-serialized data into a [Value] tree using
+// default options. See [Decoder.DecodeValue].
+func DecodeValue(data []byte) (Value, error) {
+	return defaultDecoder.DecodeValue(data)
+}
+
+// DecodeValue decodes igbinary-serialized data into a [Value] tree. Unlike
+// [Decoder.Decode], the tree keeps everything needed to encode the payload
+// again: the order and kind of array keys, class names, serialized object
+// data and back-references.
+//
+//	v, err := dec.DecodeValue(data)
+//	for _, e := range v.Get("items").Entries() {
+//	    fmt.Println(e.Key, e.Value.Get("sku").Str())
+//	}
+//
+// Classes registered with [Decoder.RegisterClass] and [WithNormalizeArrays]
+// do not apply to the tree; use [Value.Decode] to convert parts of it.
+func (d *Decoder) DecodeValue(data []byte) (Value, error) {
+	r, err := d.newReader(data)
+	if err != nil {
+		return Value{}, err
+	}
+	r.ordered = true
+	r.refs = true
+	r.tree = true
+	r.classes = nil
+	r.spl = false
+
+	val, err := r.decodeValue()
+	if err != nil {
+		return Value{}, err
+	}
+	if d.noTrailing {
+		if err := r.checkEnd(); err != nil {
+			return Value{}, err
+		}
+	}
+	return Value{v: val, tree: true}, nil
+}
+
+// Interface returns the underlying Go value. Values decoded by
+// [Decoder.Decode] use the types it returns (map[string]any, int64, string,
+// ...); trees from [Decoder.DecodeValue] and [Decoder.DecodeLazy] use
+// *[OrderedMap] for arrays and objects and *[Reference] for back-references.
+//
+// In a tree, objects are returned in the form [WithOrderedMaps] gives them,
+// with the class under [ClassKey] and the data of serialized objects under
+// [SerializedDataKey] and [SerializedValueKey]. That form cannot tell an
+// object from an array holding those keys, which the accessors of the tree
+// and [Encode] still can.
+func (v Value) Interface() any {
+	val := v.node()
+	if v.tree && hasObject(val, nil) {
+		var p plainer
+		return p.convert(val)
+	}
+	return val
+}
+
+// node returns the underlying value with a lazy subtree fully decoded.
+func (v Value) node() any {
+	if n, ok := v.v.(*lazyNode); ok {
+		return n.materialize()
+	}
+	return v.v
+}
+
+// with returns a Value of the same tree holding x.
+func (v Value) with(x any) Value {
+	return Value{v: x, tree: v.tree}
+}
+
+// hasObject reports whether the tree node x holds an *object. seen holds the
+// references followed so far, the only way back into a tree.
+func hasObject(x any, seen map[*Reference]bool) bool {
+	switch val := x.(type) {
+	case *object:
+		return true
+	case *OrderedMap:
+		for _, e := range val.Entries() {
+			if hasObject(e.Value, seen) {
+				return true
+			}
+		}
+	case *Reference:
+		if seen[val] {
+			return false
+		}
+		if seen == nil {
+			seen = make(map[*Reference]bool)
+		}
+		seen[val] = true
+		return hasObject(val.Target, seen)
+	}
+	return false
+}
+
+// plainer converts a tree holding *object nodes for Value.Interface. Every
+// container and reference is copied once, so shared and cyclic parts of the
+// tree stay shared and cyclic.
+type plainer struct {
+	done map[any]any
+}
+
+func (p *plainer) convert(x any) any {
+	if c, ok := p.done[x]; ok {
+		return c
+	}
+	if p.done == nil {
+		p.done = make(map[any]any)
+	}
+	switch val := x.(type) {
+	case *object:
+		m := NewOrderedMap(1)
+		p.done[x] = m
+		m.Set(ClassKey, val.class)
+		if val.props == nil {
+			m.Set(SerializedDataKey, val.raw)
+			if val.decoded {
+				m.Set(SerializedValueKey, p.convert(val.value))
+			}
+			return m
+		}
+		for _, e := range val.props.Entries() {
+			m.SetKey(e.PHPKey(), p.convert(e.Value))
+		}
+		return m
+	case *OrderedMap:
+		m := NewOrderedMap(val.Len())
+		p.done[x] = m
+		for _, e := range val.Entries() {
+			m.SetKey(e.PHPKey(), p.convert(e.Value))
+		}
+		return m
+	case *Reference:
+		ref := &Reference{Object: val.Object, Simple: val.Simple}
+		p.done[x] = ref
+		ref.Target = p.convert(val.Target)
+		return ref
+	}
+	return x
+}
+
+// IsNull reports whether the value is PHP NULL.
+func (v Value) IsNull() bool {
+	return v.v == nil
+}
+
+// Kind returns the PHP type of the value.
+func (v Value) Kind() Kind {
+	switch val := v.v.(type) {
+	case nil:
+		return Null
+	case bool:
+		return Bool
+	case int64:
+		return Int
+	case float64:
+		return Float
+	case string, []byte:
+		return String
+	case []any:
+		return Array
+	case *Reference:
+		return Ref
+	case *object:
+		if val.props == nil {
+			return Serialized
+		}
+		return Object
+	case *lazyNode:
+		return v.with(val.load()).Kind()
+	case map[string]any, map[Key]any, *OrderedMap:
+		if v.tree {
+			return Array
+		}
+		if _, ok := lookupEntry(val, SerializedDataKey); ok && v.Class() != "" {
+			return Serialized
+		}
+		if v.Class() != "" {
+			return Object
+		}
+		return Array
+	}
+	return Invalid
+}
+
+// deref returns the value a Ref value refers to, or v itself, with lazy
+// arrays and objects loaded one level deep.
+func (v Value) deref() Value {
+	v = v.Target()
+	if n, ok := v.v.(*lazyNode); ok {
+		return v.with(n.load())
+	}
+	return v
+}
+
+// Target returns the value a Ref value refers to, or v itself for values of
+// any other kind.
+func (v Value) Target() Value {
+	if ref, ok := v.v.(*Reference); ok {
+		return v.with(ref.Target)
+	}
+	return v
+}
+
+// Bool returns the value of a Bool.
+func (v Value) Bool() bool {
+	b, _ := v.Target().v.(bool)
+	return b
+}
+
+// Int returns the value of an Int.
+func (v Value) Int() int64 {
+	i, _ := v.Target().v.(int64)
+	return i
+}
+
+// Float returns the value of a Float.
+func (v Value) Float() float64 {
+	f, _ := v.Target().v.(float64)
+	return f
+}
+
+// Str returns the value of a String. Strings decoded with
+// [WithByteStrings] are copied.
+func (v Value) Str() string {
+	v = v.Target()
+	if b, ok := v.v.([]byte); ok {
+		return string(b)
+	}
+	s, _ := v.v.(string)
+	return s
+}
+
+// Class returns the class name of an Object or Serialized value.
+func (v Value) Class() string {
+	v = v.deref()
+	if o, ok := v.v.(*object); ok {
+		return o.class
+	}
+	if v.tree {
+		return ""
+	}
+	class, _ := lookupEntry(v.v, ClassKey)
+	s, _ := class.(string)
+	return s
+}
+
+// Raw returns the data a Serialized object was stored with, as produced by
+// its PHP serialize() or __serialize() implementation.
+func (v Value) Raw() string {
+	v = v.deref()
+	if v.Kind() != Serialized {
+		return ""
+	}
+	if o, ok := v.v.(*object); ok {
+		return o.raw
+	}
+	raw, _ := lookupEntry(v.v, SerializedDataKey)
+	s, _ := raw.(string)
+	return s
+}
+
+// Unserialized returns the decoded data of a Serialized object, when the
+// decoder was created with [WithDecodeSerialized] and the data was in a
+// format it understands, or a Null Value otherwise.
+func (v Value) Unserialized() Value {
+	v = v.deref()
+	if v.Kind() != Serialized {
+		return Value{}
+	}
+	if o, ok := v.v.(*object); ok {
+		return v.with(o.value)
+	}
+	val, _ := lookupEntry(v.v, SerializedValueKey)
+	return v.with(val)
+}
+
+// Len returns the number of entries of an Array or the number of properties
+// of an Object.
+func (v Value) Len() int {
+	v = v.deref()
+	switch val := v.v.(type) {
+	case []any:
+		return len(val)
+	case *object:
+		if val.props != nil {
+			return val.props.Len()
+		}
+	case *OrderedMap:
+		switch v.Kind() {
+		case Array:
+			return val.Len()
+		case Object:
+			return val.Len() - 1
+		}
+	case map[string]any, map[Key]any:
+		if k := v.Kind(); k == Array || k == Object {
+			entries, _, _ := mapEntries(val)
+			return len(entries)
+		}
+	}
+	return 0
+}
+
+// Index returns the value of the i-th entry of an Array or Object, in the
+// order PHP stored them, or a Null Value when i is out of range.
+func (v Value) Index(i int) Value {
+	v = v.deref()
+	if i < 0 {
+		return Value{}
+	}
+	switch val := v.v.(type) {
+	case []any:
+		if i < len(val) {
+			return v.with(val[i])
+		}
+	case *object:
+		if val.props != nil && i < val.props.Len() {
+			return v.with(val.props.At(i).Value)
+		}
+	case *OrderedMap:
+		k := v.Kind()
+		if k != Array && k != Object {
+			return Value{}
+		}
+		if pos, ok := val.index[ClassKey]; ok && k == Object && i >= pos {
+			i++
+		}
+		if i < val.Len() {
+			return v.with(val.At(i).Value)
+		}
+	default:
+		if entries := v.Entries(); i < len(entries) {
+			return entries[i].Value
+		}
+	}
+	return Value{}
+}
+
+// Get returns the value stored under key in an Array or Object, or a Null
+// Value when there is none. Integer keys are given in decimal: Get("0").
+func (v Value) Get(key string) Value {
+	val, _ := v.Lookup(key)
+	return val
+}
+
+// Lookup is like [Value.Get] but also reports whether the key exists.
+func (v Value) Lookup(key string) (Value, bool) {
+	v = v.deref()
+	switch k := v.Kind(); {
+	case k == Object && key == ClassKey && !v.tree, k != Array && k != Object:
+		return Value{}, false
+	}
+	if s, ok := v.v.([]any); ok {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(s) || strconv.Itoa(i) != key {
+			return Value{}, false
+		}
+		return v.with(s[i]), true
+	}
+	val, ok := lookupEntry(v.v, key)
+	return v.with(val), ok
+}
+
+// Entries returns the entries of an Array or the properties of an Object, in
+// the order PHP stored them. Maps decoded by [Decoder.Decode] do not keep
+// that order and are returned with sorted keys instead.
+func (v Value) Entries() []KeyValue {
+	v = v.deref()
+	if k := v.Kind(); k != Array && k != Object {
+		return nil
+	}
+	entries := v.entries()
+	kvs := make([]KeyValue, len(entries))
+	for i, e := range entries {
+		kvs[i] = KeyValue{Key: e.PHPKey(), Value: v.with(e.Value)}
+	}
+	return kvs
+}
+
+// entries returns the entries of an Array or the properties of an Object.
+func (v Value) entries() []Entry {
+	switch val := v.v.(type) {
+	case *object:
+		return val.props.Entries()
+	case *OrderedMap:
+		if v.tree {
+			return val.Entries()
+		}
+	}
+	entries, _, _ := mapEntries(v.v)
+	return entries
+}
+
+// lookupEntry returns the entry stored under key in a decoded PHP array.
+func lookupEntry(container any, key string) (any, bool) {
+	switch c := container.(type) {
+	case map[string]any:
+		val, ok := c[key]
+		return val, ok
+	case *OrderedMap:
+		return c.Get(key)
+	case *object:
+		if c.props != nil {
+			return c.props.Get(key)
+		}
+	case map[Key]any:
+		if val, ok := c[StringKey(key)]; ok {
+			return val, true
+		}
+		if i, ok := parseIntKey(key); ok {
+			val, ok := c[IntKey(i)]
+			return val, ok
+		}
+	}
+	return nil, false
+}
+
+// Decode stores the value in the Go value pointed to by target, following
+// the same rules as [Decoder.DecodeInto].
+func (v Value) Decode(target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return invalidTargetError(target)
+	}
+	return assign(rv.Elem(), v.Interface(), nil)
+}
@@ -0,0 +1,229 @@
+package igbinary_test
+
+import (
+	"reflect"
+	"testing"
+
+	igbinary "github.com/RezaKargar/go-igbinary"
+)
+
+func decodeValue(t *testing.T, data []byte) igbinary.Value {
+	t.Helper()
+	v, err := igbinary.DecodeValue(data)
+	assertNoError(t, err)
+	return v
+}
+
+func TestDecodeValueScalarKinds(t *testing.T) {
+	tests := []struct {
+		data []byte
+		kind igbinary.Kind
+	}{
+		{makePayload(0x00), igbinary.Null},
+		{makePayload(0x05), igbinary.Bool},
+		{makePayload(0x06, 0x2a), igbinary.Int},
+		{makePayload(0x0c, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0), igbinary.Float},
+		{makePayload(0x11, 0x01, 'x'), igbinary.String},
+	}
+	for _, tt := range tests {
+		if got := decodeValue(t, tt.data).Kind(); got != tt.kind {
+			t.Errorf("%x: got kind %s, want %s", tt.data, got, tt.kind)
+		}
+	}
+
+	v := decodeValue(t, makePayload(0x06, 0x2a))
+	if v.Int() != 42 || v.Str() != "" || v.Len() != 0 {
+		t.Errorf("unexpected accessors on int: %d %q %d", v.Int(), v.Str(), v.Len())
+	}
+}
+
+func TestDecodeValueArray(t *testing.T) {
+	// [5 => "int", "x" => "str"]
+	v := decodeValue(t, makePayload(
+		0x14, 0x02,
+		0x06, 0x05, 0x11, 0x03, 'i', 'n', 't',
+		0x11, 0x01, 'x', 0x11, 0x03, 's', 't', 'r',
+	))
+	if v.Kind() != igbinary.Array || v.Len() != 2 {
+		t.Fatalf("expected array of 2, got %s of %d", v.Kind(), v.Len())
+	}
+
+	entries := v.Entries()
+	if entries[0].Key != igbinary.IntKey(5) || entries[1].Key != igbinary.StringKey("x") {
+		t.Errorf("unexpected keys: %v, %v", entries[0].Key, entries[1].Key)
+	}
+	assertEqualString(t, v.Index(0).Interface(), "int")
+	assertEqualString(t, v.Index(1).Interface(), "str")
+	if !v.Index(2).IsNull() {
+		t.Error("expected out-of-range Index to be null")
+	}
+	assertEqualString(t, v.Get("5").Interface(), "int")
+	if _, ok := v.Lookup("missing"); ok {
+		t.Error("expected missing key")
+	}
+}
+
+func TestDecodeValueObject(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{
+		igbinary.ClassKey: `App\User`,
+		"id":              int64(7),
+		"tags":            []any{"a", "b"},
+	})
+	assertNoError(t, err)
+
+	v := decodeValue(t, data)
+	if v.Kind() != igbinary.Object || v.Class() != `App\User` {
+		t.Fatalf("expected App\\User object, got %s %q", v.Kind(), v.Class())
+	}
+	if v.Len() != 2 {
+		t.Errorf("expected 2 properties, got %d", v.Len())
+	}
+	if v.Get("id").Int() != 7 {
+		t.Errorf("expected id 7, got %v", v.Get("id").Interface())
+	}
+	if v.Index(0).Int() != 7 {
+		t.Errorf("expected first property to be id, got %v", v.Index(0).Interface())
+	}
+	if got := v.Get("tags").Get("1").Str(); got != "b" {
+		t.Errorf("expected tags[1] = b, got %q", got)
+	}
+	if _, ok := v.Lookup(igbinary.ClassKey); ok {
+		t.Error("expected the class name not to be a property")
+	}
+}
+
+func TestDecodeValueSerialized(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{
+		igbinary.ClassKey:          "Money",
+		igbinary.SerializedDataKey: "x:i:5;",
+	})
+	assertNoError(t, err)
+
+	v := decodeValue(t, data)
+	if v.Kind() != igbinary.Serialized || v.Class() != "Money" || v.Raw() != "x:i:5;" {
+		t.Errorf("unexpected serialized value: %s %q %q", v.Kind(), v.Class(), v.Raw())
+	}
+	if v.Len() != 0 || v.Entries() != nil {
+		t.Error("expected serialized value to have no entries")
+	}
+}
+
+func TestDecodeValueReferences(t *testing.T) {
+	node := map[string]any{igbinary.ClassKey: "Node"}
+	node["self"] = node
+	list := map[string]any{}
+	list["me"] = list
+	data, err := igbinary.Encode([]any{node, list})
+	assertNoError(t, err)
+
+	v := decodeValue(t, data)
+	self := v.Index(0).Get("self")
+	if self.Kind() != igbinary.Ref || self.Class() != "Node" {
+		t.Errorf("expected ref to Node, got %s %q", self.Kind(), self.Class())
+	}
+	if self.Target().Kind() != igbinary.Object {
+		t.Errorf("expected target object, got %s", self.Target().Kind())
+	}
+	me := v.Index(1).Get("me")
+	if me.Kind() != igbinary.Ref || me.Target().Kind() != igbinary.Array {
+		t.Errorf("expected ref to array, got %s", me.Kind())
+	}
+
+	m, ok := v.Index(0).Interface().(*igbinary.OrderedMap)
+	if !ok {
+		t.Fatalf("expected *OrderedMap, got %T", v.Index(0).Interface())
+	}
+	class, _ := m.Get(igbinary.ClassKey)
+	ref, _ := m.Get("self")
+	if class != "Node" || ref.(*igbinary.Reference).Target != m {
+		t.Errorf("unexpected object form: %v, self %#v", class, ref)
+	}
+}
+
+func TestDecodeValueRoundTrip(t *testing.T) {
+	node := map[string]any{igbinary.ClassKey: "Node", "name": "root"}
+	node["self"] = node
+	list := map[string]any{"x": int64(-3)}
+	list["me"] = list
+	ser := map[string]any{igbinary.ClassKey: "S", igbinary.SerializedDataKey: "raw"}
+	data, err := igbinary.Encode(map[string]any{
+		"node": node, "list": list, "ser": ser, "ser2": ser, "f": 1.5, "b": true, "n": nil,
+	})
+	assertNoError(t, err)
+
+	out, err := igbinary.Encode(decodeValue(t, data))
+	assertNoError(t, err)
+	if !reflect.DeepEqual(out, data) {
+		t.Errorf("round trip mismatch:\n got %x\nwant %x", out, data)
+	}
+}
+
+func TestDecodeValueClassKeyEntries(t *testing.T) {
+	// An array with a real "__class" key, and an object with a "__class"
+	// property.
+	array := makePayload(0x14, 0x01,
+		0x11, 0x07, '_', '_', 'c', 'l', 'a', 's', 's', 0x11, 0x01, 'X')
+	object := makePayload(0x17, 0x04, 'N', 'o', 'd', 'e', 0x14, 0x02,
+		0x11, 0x07, '_', '_', 'c', 'l', 'a', 's', 's', 0x11, 0x01, 'X',
+		0x11, 0x01, 'a', 0x06, 0x01)
+
+	for name, decode := range map[string]func([]byte) (igbinary.Value, error){
+		"DecodeValue": igbinary.DecodeValue,
+		"DecodeLazy":  igbinary.DecodeLazy,
+	} {
+		v, err := decode(array)
+		assertNoError(t, err)
+		if v.Kind() != igbinary.Array || v.Class() != "" || v.Len() != 1 {
+			t.Errorf("%s: expected an array of 1, got %s %q of %d", name, v.Kind(), v.Class(), v.Len())
+		}
+		assertEqualString(t, v.Get(igbinary.ClassKey).Str(), "X")
+		out, err := igbinary.Encode(v)
+		assertNoError(t, err)
+		if !reflect.DeepEqual(out, array) {
+			t.Errorf("%s: array round trip mismatch:\n got %x\nwant %x", name, out, array)
+		}
+
+		v, err = decode(object)
+		assertNoError(t, err)
+		if v.Kind() != igbinary.Object || v.Class() != "Node" || v.Len() != 2 {
+			t.Errorf("%s: expected a Node of 2, got %s %q of %d", name, v.Kind(), v.Class(), v.Len())
+		}
+		assertEqualString(t, v.Get(igbinary.ClassKey).Str(), "X")
+		assertEqualString(t, v.Index(0).Str(), "X")
+		if got := v.Entries(); len(got) != 2 || got[1].Key != igbinary.StringKey("a") {
+			t.Errorf("%s: unexpected entries %v", name, got)
+		}
+		out, err = igbinary.Encode(v)
+		assertNoError(t, err)
+		if !reflect.DeepEqual(out, object) {
+			t.Errorf("%s: object round trip mismatch:\n got %x\nwant %x", name, out, object)
+		}
+	}
+}
+
+func TestDecodeValueDecodeInto(t *testing.T) {
+	data, err := igbinary.Encode(map[string]any{"id": int64(3), "name": "Ann"})
+	assertNoError(t, err)
+
+	var u struct {
+		ID   int    `igbinary:"id"`
+		Name string `igbinary:"name"`
+	}
+	assertNoError(t, decodeValue(t, data).Decode(&u))
+	if u.ID != 3 || u.Name != "Ann" {
+		t.Errorf("unexpected result: %+v", u)
+	}
+}
+
+func TestEncodeDanglingReference(t *testing.T) {
+	_, err := igbinary.Encode(&igbinary.Reference{Target: map[string]any{}})
+	if err == nil {
+		t.Error("expected error for reference to unwritten value")
+	}
+}
+
+func TestKindString(t *testing.T) {
+	if igbinary.Serialized.String() != "serialized" || igbinary.Kind(99).String() != "Kind(99)" {
+		t.Errorf("unexpected kind names: %s, %s", igbinary.Serialized, igbinary.Kind(99))
+	}
+}